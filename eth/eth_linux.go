@@ -7,12 +7,15 @@ import (
 	"io"
 	"net"
 	"os"
+	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/lysShub/netkit/errorx"
 	netcall "github.com/lysShub/netkit/syscall"
 	"github.com/pkg/errors"
+	"golang.org/x/net/bpf"
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
@@ -24,53 +27,90 @@ type ETHConn struct {
 	ifi   *net.Interface
 	fd    *os.File
 	raw   syscall.RawConn
+
+	tsrc      TimestampSource
+	restoreTs func() error // restore nic hardware timestamp config
+
+	statsMu sync.Mutex
+	stats   Stats
 }
 
 var _ net.Conn = (*ETHConn)(nil)
 
-func Listen(network string, ifi *net.Interface) (*ETHConn, error) {
+func Listen(network string, ifi *net.Interface, opts ...Option) (*ETHConn, error) {
 	var proto tcpip.NetworkProtocolNumber
 	switch network {
 	case "eth:ip", "eth:ip4":
 		proto = header.IPv4ProtocolNumber // unix.ETH_P_IP
 	case "eth:ip6":
 		proto = header.IPv6ProtocolNumber // unix.ETH_P_IPV6
+	case "eth:arp":
+		proto = header.ARPProtocolNumber // unix.ETH_P_ARP
 	default:
 		// todo: support unix.ETH_P_ALL
 		return nil, errors.Errorf("not support network %s", network)
 	}
+	cfg := Options(opts...)
+
+	bind := uint16(proto)
+	if cfg.vlan {
+		bind = unix.ETH_P_ALL
+	}
 
-	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(netcall.Hton(uint16(proto))))
+	// protocol 0 not receive any frame before bind
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.vlan {
+		if err = netcall.SetBPF(uintptr(fd), protoFilter(proto)); err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+
 	if err = unix.Bind(fd, &unix.SockaddrLinklayer{
-		Protocol: netcall.Hton(uint16(proto)),
+		Protocol: netcall.Hton(bind),
 		Ifindex:  ifi.Index,
 		Pkttype:  unix.PACKET_HOST,
 	}); err != nil {
+		unix.Close(fd)
 		return nil, err
 	}
 
-	// for support deadline
-	if err = unix.SetNonblock(fd, true); err != nil {
-		return nil, err
+	// report vlan tag stripped by kernel
+	if err = unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_AUXDATA, 1); err != nil {
+		unix.Close(fd)
+		return nil, errors.WithStack(err)
 	}
 
-	f := os.NewFile(uintptr(fd), "")
-	raw, err := f.SyscallConn()
+	tsrc, restoreTs, err := enableTimestamp(fd, ifi.Name, cfg.timestamp)
 	if err != nil {
 		unix.Close(fd)
 		return nil, err
 	}
 
-	return &ETHConn{
-		proto: proto,
-		ifi:   ifi,
-		fd:    f,
-		raw:   raw,
-	}, nil
+	// for support deadline
+	if err = unix.SetNonblock(fd, true); err == nil {
+		f := os.NewFile(uintptr(fd), "")
+		var raw syscall.RawConn
+		if raw, err = f.SyscallConn(); err == nil {
+			return &ETHConn{
+				proto:     proto,
+				ifi:       ifi,
+				fd:        f,
+				raw:       raw,
+				tsrc:      tsrc,
+				restoreTs: restoreTs,
+			}, nil
+		}
+	}
+	unix.Close(fd)
+	if restoreTs != nil {
+		restoreTs()
+	}
+	return nil, errors.WithStack(err)
 }
 
 // todo: support dial
@@ -90,35 +130,103 @@ func (c *ETHConn) Read(eth []byte) (n int, err error) {
 }
 
 func (c *ETHConn) ReadFromETH(ip []byte) (n int, from net.HardwareAddr, err error) {
+	n, _, from, err = c.readFrom(ip, nil)
+	return n, from, err
+}
+
+// Info ancillary information of received frame
+type Info struct {
+	From net.HardwareAddr
+
+	// VLAN outer vlan tag of the frame, only valid when Tagged, on SOCK_DGRAM
+	// socket the tag has been stripped by kernel(or nic), recovered from
+	// PACKET_AUXDATA tp_vlan_tci.
+	VLAN   VLAN
+	Tagged bool
+
+	// Timestamp receive timestamp, require Timestamp option
+	Timestamp       time.Time
+	TimestampSource TimestampSource
+}
+
+// ReadMsgETH read ip packet and ancillary information
+func (c *ETHConn) ReadMsgETH(ip []byte) (n int, info Info, err error) {
+	oobp := oobPool.Get().(*[]byte)
+	defer oobPool.Put(oobp)
+	oob := *oobp
+
+	var oobn int
+	n, oobn, info.From, err = c.readFrom(ip, oob)
+	if err != nil {
+		return 0, Info{}, err
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return 0, Info{}, errors.WithStack(err)
+	}
+	for _, e := range msgs {
+		if e.Header.Level == unix.SOL_PACKET && e.Header.Type == unix.PACKET_AUXDATA &&
+			len(e.Data) >= sizeofTpacketAuxdata {
+
+			aux := (*unix.TpacketAuxdata)(unsafe.Pointer(unsafe.SliceData(e.Data)))
+			if aux.Status&unix.TP_STATUS_VLAN_VALID != 0 {
+				tpid := TPID8021Q
+				if aux.Status&unix.TP_STATUS_VLAN_TPID_VALID != 0 {
+					tpid = aux.Vlan_tpid
+				}
+				info.VLAN, info.Tagged = VLANFromTCI(tpid, aux.Vlan_tci), true
+			}
+		} else if ts, src := parseTimestamp(&e); src != TimestampNone {
+			info.Timestamp, info.TimestampSource = ts, src
+		}
+	}
+	if c.tsrc != TimestampNone && info.TimestampSource == TimestampNone {
+		info.Timestamp, info.TimestampSource = time.Now(), TimestampUser
+	}
+	return n, info, nil
+}
+
+var oobPool = sync.Pool{
+	New: func() any {
+		var oob = make([]byte, unix.CmsgSpace(sizeofTpacketAuxdata)+unix.CmsgSpace(sizeofScmTimestamping))
+		return &oob
+	},
+}
+
+func (c *ETHConn) readFrom(ip, oob []byte) (n, oobn int, from net.HardwareAddr, err error) {
 	var src unix.Sockaddr
 	var operr error
 	if err = c.raw.Read(func(fd uintptr) (done bool) {
 		// sometime, it return n is greater 6 than actual size,
-		_, src, operr = unix.Recvfrom(int(fd), ip, unix.MSG_TRUNC)
+		n, oobn, _, src, operr = unix.Recvmsg(int(fd), ip, oob, unix.MSG_TRUNC)
 		return opdone(operr)
 	}); err != nil {
-		return 0, nil, err
+		return 0, 0, nil, err
 	}
 	if operr != nil {
-		return 0, nil, operr
+		return 0, 0, nil, operr
 	}
 
-	switch header.IPVersion(ip) {
-	case 4:
-		n = int(header.IPv4(ip).TotalLength())
-	case 6:
-		n = int(header.IPv6(ip).PayloadLength() + header.IPv6FixedHeaderSize)
-	default:
-		return 0, nil, errors.Errorf("recved invalid ip packet: %#v", ip[:min(20, len(ip))])
+	// arp packet use received size, maybe include ethernet padding
+	if c.proto != header.ARPProtocolNumber {
+		switch header.IPVersion(ip) {
+		case 4:
+			n = int(header.IPv4(ip).TotalLength())
+		case 6:
+			n = int(header.IPv6(ip).PayloadLength() + header.IPv6FixedHeaderSize)
+		default:
+			return 0, 0, nil, errors.Errorf("recved invalid ip packet: %#v", ip[:min(20, len(ip))])
+		}
 	}
 	if n > len(ip) {
-		return 0, nil, errors.WithStack(errorx.Temporary(io.ErrShortBuffer))
+		return 0, 0, nil, errors.WithStack(errorx.Temporary(io.ErrShortBuffer))
 	}
 
 	if src, ok := src.(*unix.SockaddrLinklayer); ok {
 		from = src.Addr[:src.Halen]
 	}
-	return n, from, nil
+	return n, oobn, from, nil
 }
 
 func (c *ETHConn) Write(eth []byte) (n int, err error) {
@@ -131,29 +239,61 @@ func (c *ETHConn) Write(eth []byte) (n int, err error) {
 }
 
 func (c *ETHConn) WriteToETH(ip []byte, hw net.HardwareAddr) (int, error) {
+	return c.writeTo([][]byte{ip}, uint16(c.proto), hw)
+}
+
+// WriteToETHVLAN write ip packet with vlan tags, outer tag first.
+func (c *ETHConn) WriteToETHVLAN(ip []byte, hw net.HardwareAddr, tags ...VLAN) (int, error) {
+	if len(tags) == 0 {
+		return c.WriteToETH(ip, hw)
+	}
+
+	// kernel fill ether type by sll_protocol, so the outer tpid as
+	// sll_protocol, and prepend the remaining tag section before ip:
+	//  | tci | tpid | tci | ... | type | ip |
+	var tag = make([]byte, len(tags)*VLANTagSize+2)
+	encodeTags(tag, tags, c.proto)
+
+	n, err := c.writeTo([][]byte{tag[2:], ip}, tags[0].tpid(), hw)
+	if err != nil {
+		return 0, err
+	}
+	return n - len(tag[2:]), nil
+}
+
+func (c *ETHConn) writeTo(bs [][]byte, proto uint16, hw net.HardwareAddr) (int, error) {
 	dst := &unix.SockaddrLinklayer{
-		Protocol: netcall.Hton(uint16(c.proto)),
+		Protocol: netcall.Hton(proto),
 		Ifindex:  c.ifi.Index,
 		Pkttype:  unix.PACKET_HOST,
 		Halen:    uint8(len(hw)),
 	}
 	copy(dst.Addr[:], hw)
 
+	var n int
 	var err, operr error
 	if err = c.raw.Write(func(fd uintptr) (done bool) {
-		operr = unix.Sendto(int(fd), ip, 0, dst)
+		n, operr = unix.SendmsgBuffers(int(fd), bs, nil, dst, 0)
 		return opdone(operr)
 	}); err != nil {
 		return 0, err
 	}
 	if operr != nil {
-		return 0, err
+		return 0, errors.WithStack(operr)
 	}
+	return n, nil
+}
 
-	return len(ip), nil
+// Close close the conn, and restore nic hardware timestamp config if changed
+// by Timestamp(TimestampHardware).
+func (c *ETHConn) Close() error {
+	err := c.fd.Close()
+	if err == nil && c.restoreTs != nil {
+		err = c.restoreTs()
+	}
+	return err
 }
 
-func (c *ETHConn) Close() error                          { return c.fd.Close() }
 func (c *ETHConn) LocalAddr() net.Addr                   { return ETHAddr(c.ifi.HardwareAddr) }
 func (c *ETHConn) RemoteAddr() net.Addr                  { return nil }
 func (c *ETHConn) SyscallConn() (syscall.RawConn, error) { return c.raw, nil }
@@ -162,11 +302,33 @@ func (c *ETHConn) SetReadDeadline(t time.Time) error     { return c.fd.SetReadDe
 func (c *ETHConn) SetWriteDeadline(t time.Time) error    { return c.fd.SetWriteDeadline(t) }
 func (c *ETHConn) Interface() *net.Interface             { return c.ifi }
 
+// TimestampSource the receive timestamp source actually used, decided by
+// Timestamp option and system support, notice hardware source maybe fallback
+// to software for some frames, see Info.TimestampSource.
+func (c *ETHConn) TimestampSource() TimestampSource { return c.tsrc }
+
 type ETHAddr net.HardwareAddr
 
 func (e ETHAddr) Network() string { return "eth" }
 func (e ETHAddr) String() string  { return net.HardwareAddr(e).String() }
 
+// protoFilter accept incoming frames of the network protocol
+func protoFilter(proto tcpip.NetworkProtocolNumber) []bpf.Instruction {
+	return []bpf.Instruction{
+		bpf.LoadExtension{Num: bpf.ExtType},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.PACKET_OUTGOING, SkipTrue: 2},
+		bpf.LoadExtension{Num: bpf.ExtProto},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(proto), SkipTrue: 1},
+		bpf.RetConstant{Val: 0},
+		bpf.RetConstant{Val: 0xffffffff},
+	}
+}
+
+const (
+	sizeofTpacketAuxdata  = int(unsafe.Sizeof(unix.TpacketAuxdata{}))
+	sizeofScmTimestamping = int(unsafe.Sizeof(unix.Timespec{})) * 3
+)
+
 func opdone(operr error) bool {
 	return operr != syscall.EWOULDBLOCK && operr != syscall.EAGAIN
 }
@@ -15,9 +15,11 @@ import (
 	"time"
 
 	"github.com/lysShub/netkit/route"
+	"github.com/lysShub/netkit/test"
 	"github.com/lysShub/netkit/tun"
 	"github.com/mdlayher/arp"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/bpf"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
 )
@@ -437,3 +439,173 @@ func Test_Tun_Device(t *testing.T) {
 		// }
 	})
 }
+
+func Test_Fanout(t *testing.T) {
+	t.Run("unique", func(t *testing.T) {
+		conns, err := ListenFanout("eth:ip4", lo, 4, Fanout{Mode: FanoutHash, Flags: FanoutFlagDefrag})
+		require.NoError(t, err)
+		defer func() {
+			for _, e := range conns {
+				e.Close()
+			}
+		}()
+		require.Equal(t, 4, len(conns))
+
+		id, err := conns[0].FanoutGroup()
+		require.NoError(t, err)
+		for _, e := range conns[1:] {
+			i, err := e.FanoutGroup()
+			require.NoError(t, err)
+			require.Equal(t, id, i)
+		}
+	})
+
+	t.Run("cbpf", func(t *testing.T) {
+		conns, err := ListenFanout("eth:ip4", lo, 2, Fanout{
+			Group: 0x1234, Mode: FanoutCBPF,
+			Prog: []bpf.Instruction{bpf.RetConstant{Val: 1}},
+		})
+		require.NoError(t, err)
+		defer func() {
+			for _, e := range conns {
+				e.Close()
+			}
+		}()
+
+		for _, e := range conns {
+			i, err := e.FanoutGroup()
+			require.NoError(t, err)
+			require.Equal(t, uint16(0x1234), i)
+		}
+	})
+
+	t.Run("cbpf-without-prog", func(t *testing.T) {
+		_, err := ListenFanout("eth:ip4", lo, 2, Fanout{Mode: FanoutCBPF})
+		require.Error(t, err)
+	})
+}
+
+func Test_VLAN_Loopback(t *testing.T) {
+	var (
+		caddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 19987)
+		saddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 8080)
+		tag   = VLAN{TPID: TPID8021Q, PCP: 2, ID: 123}
+	)
+	ip := test.BuildIP(t, caddr, saddr, header.UDPProtocolNumber, 0, 0, []byte("hello"))
+
+	conn, err := Listen("eth:ip4", lo, VLANAware)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.WriteToETHVLAN(ip, make(net.HardwareAddr, 6), tag)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*3)))
+
+	var b = make(header.IPv4, 1536)
+	for {
+		n, info, err := conn.ReadMsgETH(b[:cap(b)])
+		require.NoError(t, err)
+		b = b[:n]
+
+		if b.Protocol() == uint8(header.UDPProtocolNumber) {
+			udp := header.UDP(b[b.HeaderLength():])
+			if udp.SourcePort() == caddr.Port() && udp.DestinationPort() == saddr.Port() {
+				require.True(t, info.Tagged)
+				require.Equal(t, tag, info.VLAN)
+				return
+			}
+		}
+	}
+}
+
+func Test_Timestamp(t *testing.T) {
+	var (
+		caddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 19988)
+		saddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 8080)
+	)
+
+	for _, src := range []TimestampSource{TimestampUser, TimestampSoftware, TimestampHardware} {
+		t.Run(src.String(), func(t *testing.T) {
+			conn, err := Listen("eth:ip4", lo, Timestamp(src))
+			require.NoError(t, err)
+			defer conn.Close()
+			require.LessOrEqual(t, conn.TimestampSource(), src)
+			if src != TimestampHardware {
+				require.Equal(t, src, conn.TimestampSource())
+			}
+
+			s := time.Now()
+			_, err = conn.WriteToETH(test.BuildIP(t, caddr, saddr, header.UDPProtocolNumber, 0, 0, []byte("hello")), make(net.HardwareAddr, 6))
+			require.NoError(t, err)
+
+			var b = make(header.IPv4, 1536)
+			for {
+				n, info, err := conn.ReadMsgETH(b[:cap(b)])
+				require.NoError(t, err)
+				b = b[:n]
+
+				if b.Protocol() == uint8(header.UDPProtocolNumber) &&
+					header.UDP(b.Payload()).SourcePort() == caddr.Port() {
+
+					require.Equal(t, conn.TimestampSource(), info.TimestampSource)
+					require.False(t, info.Timestamp.Before(s.Add(-time.Millisecond)))
+					require.Less(t, time.Since(info.Timestamp), time.Second)
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		conn, err := Listen("eth:ip4", lo)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, TimestampNone, conn.TimestampSource())
+	})
+}
+
+func Test_Stats(t *testing.T) {
+	var (
+		caddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 19989)
+		saddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 8080)
+	)
+
+	conn, err := Listen("eth:ip4", lo)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("buffer", func(t *testing.T) {
+		err := conn.SetReadBuffer(1 << 20)
+		require.NoError(t, err)
+		err = conn.SetWriteBuffer(1 << 19)
+		require.NoError(t, err)
+
+		st, err := conn.Stats()
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, st.ReadBuffer, 1<<20)
+		require.GreaterOrEqual(t, st.WriteBuffer, 1<<19)
+
+		require.Error(t, conn.SetReadBuffer(0))
+	})
+
+	t.Run("packets", func(t *testing.T) {
+		st1, err := conn.Stats()
+		require.NoError(t, err)
+
+		const n = 8
+		for i := 0; i < n; i++ {
+			_, err = conn.WriteToETH(test.BuildIP(t, caddr, saddr, header.UDPProtocolNumber, 0, 0, []byte("hello")), make(net.HardwareAddr, 6))
+			require.NoError(t, err)
+		}
+		time.Sleep(time.Millisecond * 100)
+
+		st2, err := conn.Stats()
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, st2.Packets-st1.Packets, uint64(n))
+
+		// accumulated
+		st3, err := conn.Stats()
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, st3.Packets, st2.Packets)
+	})
+}
@@ -0,0 +1,166 @@
+//go:build linux
+// +build linux
+
+package eth
+
+import (
+	"net"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// FanoutMode PACKET_FANOUT algorithm, decide which socket of the group
+// receive the frame, https://man7.org/linux/man-pages/man7/packet.7.html
+type FanoutMode uint16
+
+const (
+	// FanoutHash select socket by flow hash, the frames of the same flow
+	// always delivered to the same socket
+	FanoutHash FanoutMode = unix.PACKET_FANOUT_HASH
+	// FanoutLB round-robin
+	FanoutLB FanoutMode = unix.PACKET_FANOUT_LB
+	// FanoutCPU select socket by the cpu that the frame arrived on
+	FanoutCPU FanoutMode = unix.PACKET_FANOUT_CPU
+	// FanoutRandom select socket by random
+	FanoutRandom FanoutMode = unix.PACKET_FANOUT_RND
+	// FanoutRollover fill one socket, until it's backlogged, then move to next
+	FanoutRollover FanoutMode = unix.PACKET_FANOUT_ROLLOVER
+	// FanoutCBPF select socket by classic bpf program, see Fanout.Prog
+	FanoutCBPF FanoutMode = unix.PACKET_FANOUT_CBPF
+)
+
+func (m FanoutMode) String() string {
+	switch m {
+	case FanoutHash:
+		return "hash"
+	case FanoutLB:
+		return "lb"
+	case FanoutCPU:
+		return "cpu"
+	case FanoutRandom:
+		return "random"
+	case FanoutRollover:
+		return "rollover"
+	case FanoutCBPF:
+		return "cbpf"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// FanoutFlagRollover rollover to other socket when selected socket backlogged
+	FanoutFlagRollover uint16 = unix.PACKET_FANOUT_FLAG_ROLLOVER
+	// FanoutFlagDefrag defragment ip packet before fanout, avoid fragments of
+	// one packet be delivered to different socket
+	FanoutFlagDefrag uint16 = unix.PACKET_FANOUT_FLAG_DEFRAG
+	// FanoutFlagIgnoreOutgoing ignore outgoing frames
+	FanoutFlagIgnoreOutgoing uint16 = unix.PACKET_FANOUT_FLAG_IGNORE_OUTGOING
+)
+
+type Fanout struct {
+	// Group fanout group id, 0 means let kernel allocate an unique id, only
+	// valid when as the first member of group, require linux 4.4+
+	Group uint16
+
+	Mode  FanoutMode
+	Flags uint16
+
+	// Prog classic bpf program used by FanoutCBPF, return value is the
+	// index of socket in the group(modulo group size)
+	Prog []bpf.Instruction
+}
+
+// JoinFanout join conn to a fanout group, all members of group must listen
+// the same network and interface.
+func (c *ETHConn) JoinFanout(f Fanout) error {
+	if f.Mode == FanoutCBPF && len(f.Prog) == 0 {
+		return errors.New("fanout cbpf mode require bpf program")
+	}
+	if f.Group == 0 {
+		f.Flags |= unix.PACKET_FANOUT_FLAG_UNIQUEID
+	}
+	return c.joinFanout(f)
+}
+
+func (c *ETHConn) joinFanout(f Fanout) error {
+	arg := int(f.Group) | int(uint16(f.Mode)|f.Flags)<<16
+
+	var operr error
+	if err := c.raw.Control(func(fd uintptr) {
+		operr = unix.SetsockoptInt(int(fd), unix.SOL_PACKET, unix.PACKET_FANOUT, arg)
+		if operr != nil || f.Mode != FanoutCBPF {
+			return
+		}
+
+		ins, err := bpf.Assemble(f.Prog)
+		if err != nil {
+			operr = err
+			return
+		}
+		prog := &unix.SockFprog{
+			Len:    uint16(len(ins)),
+			Filter: (*unix.SockFilter)(unsafe.Pointer(&ins[0])),
+		}
+		operr = unix.SetsockoptSockFprog(int(fd), unix.SOL_PACKET, unix.PACKET_FANOUT_DATA, prog)
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(operr)
+}
+
+// FanoutGroup get joined fanout group id
+func (c *ETHConn) FanoutGroup() (uint16, error) {
+	var (
+		val   int
+		operr error
+	)
+	if err := c.raw.Control(func(fd uintptr) {
+		val, operr = unix.GetsockoptInt(int(fd), unix.SOL_PACKET, unix.PACKET_FANOUT)
+	}); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if operr != nil {
+		return 0, errors.WithStack(operr)
+	}
+	return uint16(val), nil
+}
+
+// ListenFanout listen workers conns which in the same fanout group, usually
+// one conn per worker goroutine.
+func ListenFanout(network string, ifi *net.Interface, workers int, f Fanout) ([]*ETHConn, error) {
+	if workers <= 0 {
+		return nil, errors.Errorf("invalid workers %d", workers)
+	}
+
+	var conns = make([]*ETHConn, 0, workers)
+	for i := 0; i < workers; i++ {
+		conn, err := Listen(network, ifi)
+		if err == nil {
+			if i == 0 || f.Group != 0 {
+				err = conn.JoinFanout(f)
+			} else {
+				err = conn.joinFanout(f)
+			}
+			if err == nil && i == 0 && f.Group == 0 {
+				// the following members join group allocated by kernel,
+				// notice the allocated id maybe 0
+				f.Group, err = conn.FanoutGroup()
+			}
+			if err != nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			for _, e := range conns {
+				e.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
@@ -0,0 +1,35 @@
+package eth
+
+type Option func(*Configs)
+
+func Options(opts ...Option) *Configs {
+	cfg := &Configs{}
+	for _, e := range opts {
+		e(cfg)
+	}
+	return cfg
+}
+
+// VLANAware receive vlan tagged frames and report the tag by ETHConn.ReadMsgETH.
+//
+// kernel clear the stripped vlan tag before deliver frame to socket that bound
+// specified protocol, so the conn bind ETH_P_ALL and filter frames by bpf.
+func VLANAware(c *Configs) {
+	c.vlan = true
+}
+
+// Timestamp report receive timestamp by ETHConn.ReadMsgETH, try src
+// firstly, fallback to lower precision source if not supported, use
+// ETHConn.TimestampSource query the actually used source. notice the
+// TimestampHardware change the nic's global hardware timestamp config if not
+// enabled, it be restored after ETHConn closed.
+func Timestamp(src TimestampSource) Option {
+	return func(c *Configs) {
+		c.timestamp = src
+	}
+}
+
+type Configs struct {
+	vlan      bool
+	timestamp TimestampSource
+}
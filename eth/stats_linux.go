@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package eth
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+type Stats struct {
+	// Packets received frames since Listen, include Drops
+	Packets uint64
+	// Drops frames dropped by kernel, usually because of receive buffer full
+	Drops uint64
+	// FreezeQueue times of receive queue freeze, only valid for TPACKET_V3
+	FreezeQueue uint64
+
+	// socket buffer size, notice kernel double the value set by SetReadBuffer/SetWriteBuffer
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// Stats get PACKET_STATISTICS counters and socket buffer size, kernel
+// reset the counters after each query, so the conn accumulates them.
+func (c *ETHConn) Stats() (Stats, error) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	var (
+		st         *unix.TpacketStatsV3
+		rbuf, wbuf int
+		operr      error
+	)
+	if err := c.raw.Control(func(fd uintptr) {
+		st, operr = unix.GetsockoptTpacketStatsV3(int(fd), unix.SOL_PACKET, unix.PACKET_STATISTICS)
+		if operr != nil {
+			return
+		}
+		rbuf, operr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF)
+		if operr != nil {
+			return
+		}
+		wbuf, operr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF)
+	}); err != nil {
+		return Stats{}, errors.WithStack(err)
+	}
+	if operr != nil {
+		return Stats{}, errors.WithStack(operr)
+	}
+
+	c.stats.Packets += uint64(st.Packets)
+	c.stats.Drops += uint64(st.Drops)
+	c.stats.FreezeQueue += uint64(st.Freeze_q_cnt)
+	c.stats.ReadBuffer, c.stats.WriteBuffer = rbuf, wbuf
+	return c.stats, nil
+}
+
+// SetReadBuffer set socket receive buffer size, try SO_RCVBUFFORCE firstly, it
+// can exceed net.core.rmem_max but require CAP_NET_ADMIN.
+func (c *ETHConn) SetReadBuffer(bytes int) error {
+	return c.setBuffer(unix.SO_RCVBUFFORCE, unix.SO_RCVBUF, bytes)
+}
+
+// SetWriteBuffer set socket send buffer size, try SO_SNDBUFFORCE firstly, it
+// can exceed net.core.wmem_max but require CAP_NET_ADMIN.
+func (c *ETHConn) SetWriteBuffer(bytes int) error {
+	return c.setBuffer(unix.SO_SNDBUFFORCE, unix.SO_SNDBUF, bytes)
+}
+
+func (c *ETHConn) setBuffer(force, opt int, bytes int) error {
+	if bytes <= 0 {
+		return errors.Errorf("invalid buffer size %d", bytes)
+	}
+
+	var operr error
+	if err := c.raw.Control(func(fd uintptr) {
+		operr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, force, bytes)
+		if operr == unix.EPERM {
+			operr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, opt, bytes)
+		}
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(operr)
+}
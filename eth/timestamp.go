@@ -0,0 +1,31 @@
+package eth
+
+// TimestampSource the source of received frame timestamp, the
+// fallback chain is: hardware -> software -> user.
+type TimestampSource uint8
+
+const (
+	// TimestampNone not report timestamp
+	TimestampNone TimestampSource = iota
+	// TimestampUser userspace time.Now() after frame received
+	TimestampUser
+	// TimestampSoftware kernel software timestamp, by SO_TIMESTAMPING or SO_TIMESTAMPNS
+	TimestampSoftware
+	// TimestampHardware nic hardware timestamp, by SO_TIMESTAMPING
+	TimestampHardware
+)
+
+func (s TimestampSource) String() string {
+	switch s {
+	case TimestampNone:
+		return "none"
+	case TimestampUser:
+		return "user"
+	case TimestampSoftware:
+		return "software"
+	case TimestampHardware:
+		return "hardware"
+	default:
+		return "unknown"
+	}
+}
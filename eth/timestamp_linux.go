@@ -0,0 +1,118 @@
+//go:build linux
+// +build linux
+
+package eth
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// enableTimestamp enable receive timestamp, return the actually source, and
+// restore function of nic hardware timestamp config if changed, it's nil if
+// not changed.
+func enableTimestamp(fd int, ifi string, src TimestampSource) (TimestampSource, func() error, error) {
+	const software = unix.SOF_TIMESTAMPING_RX_SOFTWARE | unix.SOF_TIMESTAMPING_SOFTWARE
+
+	switch src {
+	case TimestampNone, TimestampUser:
+		return src, nil, nil
+	case TimestampHardware:
+		if restore, err := enableHwTimestamp(ifi); err == nil {
+			// also require software timestamp, as fallback for frame without hardware timestamp
+			err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPING,
+				unix.SOF_TIMESTAMPING_RX_HARDWARE|unix.SOF_TIMESTAMPING_RAW_HARDWARE|software,
+			)
+			if err == nil {
+				return TimestampHardware, restore, nil
+			} else if restore != nil {
+				restore()
+			}
+		}
+		fallthrough
+	case TimestampSoftware:
+		err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPING, software)
+		if err == nil {
+			return TimestampSoftware, nil, nil
+		}
+		err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+		if err == nil {
+			return TimestampSoftware, nil, nil
+		}
+		return TimestampUser, nil, nil
+	default:
+		return 0, nil, errors.Errorf("invalid timestamp source %d", src)
+	}
+}
+
+// enableHwTimestamp enable nic receive hardware timestamp, it's a global config
+// of the nic, keep it if already enabled(such as by ptp4l). return restore
+// function of the old config if changed.
+func enableHwTimestamp(ifi string) (func() error, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer unix.Close(fd)
+
+	old, err := unix.IoctlGetHwTstamp(fd, ifi)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if old.Rx_filter != unix.HWTSTAMP_FILTER_NONE {
+		return nil, nil
+	}
+
+	cfg := *old
+	cfg.Rx_filter = unix.HWTSTAMP_FILTER_ALL
+	if err = unix.IoctlSetHwTstamp(fd, ifi, &cfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	restore := func() error {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer unix.Close(fd)
+		return errors.WithStack(unix.IoctlSetHwTstamp(fd, ifi, old))
+	}
+
+	if cfg.Rx_filter == unix.HWTSTAMP_FILTER_NONE {
+		restore()
+		return nil, errors.New("not support hardware timestamp")
+	}
+	return restore, nil
+}
+
+// parseTimestamp parse timestamp from SCM_TIMESTAMPING or SCM_TIMESTAMPNS
+// control message, return zero time if not found
+func parseTimestamp(msg *unix.SocketControlMessage) (time.Time, TimestampSource) {
+	const size = int(unsafe.Sizeof(unix.Timespec{}))
+	if msg.Header.Level != unix.SOL_SOCKET {
+		return time.Time{}, TimestampNone
+	}
+
+	switch msg.Header.Type {
+	case unix.SCM_TIMESTAMPING:
+		// struct scm_timestamping { struct timespec ts[3]; }, ts[0] is software
+		// timestamp, ts[2] is raw hardware timestamp, ts[1] deprecated
+		if len(msg.Data) < 3*size {
+			return time.Time{}, TimestampNone
+		}
+		ts := unsafe.Slice((*unix.Timespec)(unsafe.Pointer(unsafe.SliceData(msg.Data))), 3)
+		if ts[2].Sec != 0 || ts[2].Nsec != 0 {
+			return time.Unix(ts[2].Unix()), TimestampHardware
+		} else if ts[0].Sec != 0 || ts[0].Nsec != 0 {
+			return time.Unix(ts[0].Unix()), TimestampSoftware
+		}
+	case unix.SCM_TIMESTAMPNS:
+		if len(msg.Data) < size {
+			return time.Time{}, TimestampNone
+		}
+		ts := (*unix.Timespec)(unsafe.Pointer(unsafe.SliceData(msg.Data)))
+		return time.Unix(ts.Unix()), TimestampSoftware
+	}
+	return time.Time{}, TimestampNone
+}
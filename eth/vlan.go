@@ -0,0 +1,139 @@
+package eth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+const (
+	TPID8021Q  uint16 = 0x8100 // unix.ETH_P_8021Q
+	TPID8021AD uint16 = 0x88a8 // unix.ETH_P_8021AD, QinQ outer tag
+
+	VLANTagSize = 4
+)
+
+// VLAN 802.1Q/802.1ad tag
+type VLAN struct {
+	TPID uint16 // TPID8021Q or TPID8021AD, default TPID8021Q
+
+	PCP uint8 // priority code point, 3 bits
+	DEI bool  // drop eligible indicator
+	ID  uint16
+}
+
+// VLANFromTCI parse vlan tag from tag control information
+func VLANFromTCI(tpid, tci uint16) VLAN {
+	return VLAN{
+		TPID: tpid,
+		PCP:  uint8(tci >> 13),
+		DEI:  tci&0x1000 != 0,
+		ID:   tci & 0x0fff,
+	}
+}
+
+// TCI tag control information
+func (v VLAN) TCI() uint16 {
+	tci := uint16(v.PCP&0x7)<<13 | v.ID&0x0fff
+	if v.DEI {
+		tci |= 0x1000
+	}
+	return tci
+}
+
+func (v VLAN) tpid() uint16 {
+	if v.TPID == 0 {
+		return TPID8021Q
+	}
+	return v.TPID
+}
+
+func (v VLAN) String() string {
+	return fmt.Sprintf("0x%04x:%d(pcp %d)", v.tpid(), v.ID, v.PCP)
+}
+
+// Frame ethernet frame, with optional vlan tags
+type Frame struct {
+	Dst, Src net.HardwareAddr
+
+	// Tags vlan tags, outer first, QinQ has two tags
+	Tags []VLAN
+
+	Type    tcpip.NetworkProtocolNumber
+	Payload []byte
+}
+
+// ParseFrame parse ethernet frame, the Payload refer b.
+func ParseFrame(b []byte) (*Frame, error) {
+	if len(b) < header.EthernetMinimumSize {
+		return nil, errors.Errorf("invalid ethernet frame size %d", len(b))
+	}
+
+	eth := header.Ethernet(b)
+	var f = &Frame{
+		Dst:  net.HardwareAddr(eth[0:6]),
+		Src:  net.HardwareAddr(eth[6:12]),
+		Type: eth.Type(),
+	}
+	b = b[header.EthernetMinimumSize:]
+	for uint16(f.Type) == TPID8021Q || uint16(f.Type) == TPID8021AD {
+		if len(b) < VLANTagSize {
+			return nil, errors.New("invalid vlan tag")
+		}
+		f.Tags = append(f.Tags, VLANFromTCI(uint16(f.Type), binary.BigEndian.Uint16(b)))
+		f.Type = tcpip.NetworkProtocolNumber(binary.BigEndian.Uint16(b[2:]))
+		b = b[VLANTagSize:]
+	}
+	f.Payload = b
+	return f, nil
+}
+
+// HeaderSize ethernet header size, include vlan tags
+func (f *Frame) HeaderSize() int {
+	return header.EthernetMinimumSize + len(f.Tags)*VLANTagSize
+}
+
+func (f *Frame) Size() int {
+	return f.HeaderSize() + len(f.Payload)
+}
+
+// Encode encode frame to b, return encoded size
+func (f *Frame) Encode(b []byte) (int, error) {
+	if len(b) < f.Size() {
+		return 0, errors.Errorf("short buffer %d, require %d", len(b), f.Size())
+	} else if len(f.Dst) != 6 || len(f.Src) != 6 {
+		return 0, errors.Errorf("invalid hardware address %s, %s", f.Dst, f.Src)
+	}
+
+	copy(b[0:], f.Dst)
+	copy(b[6:], f.Src)
+	n := 12
+	n += encodeTags(b[n:], f.Tags, f.Type)
+	n += copy(b[n:], f.Payload)
+	return n, nil
+}
+
+func (f *Frame) Marshal() ([]byte, error) {
+	var b = make([]byte, f.Size())
+	n, err := f.Encode(b)
+	return b[:n], err
+}
+
+// encodeTags encode vlan tags and inner ether type, b not include
+// dst/src address, such as:
+//
+//	| tpid | tci | ... | type |
+func encodeTags(b []byte, tags []VLAN, typ tcpip.NetworkProtocolNumber) int {
+	n := 0
+	for _, e := range tags {
+		binary.BigEndian.PutUint16(b[n:], e.tpid())
+		binary.BigEndian.PutUint16(b[n+2:], e.TCI())
+		n += VLANTagSize
+	}
+	binary.BigEndian.PutUint16(b[n:], uint16(typ))
+	return n + 2
+}
@@ -0,0 +1,92 @@
+package eth
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+func Test_VLAN_TCI(t *testing.T) {
+	v := VLAN{TPID: TPID8021Q, PCP: 5, DEI: true, ID: 100}
+	require.Equal(t, uint16(5<<13|0x1000|100), v.TCI())
+	require.Equal(t, v, VLANFromTCI(TPID8021Q, v.TCI()))
+
+	v = VLAN{ID: 0xffff}
+	require.Equal(t, uint16(0x0fff), v.TCI())
+}
+
+func Test_Frame(t *testing.T) {
+	var (
+		dst = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+		src = net.HardwareAddr{0x00, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e}
+		ip  = []byte{0x45, 0, 0, 20}
+	)
+
+	t.Run("untagged", func(t *testing.T) {
+		f := &Frame{Dst: dst, Src: src, Type: header.IPv4ProtocolNumber, Payload: ip}
+		b, err := f.Marshal()
+		require.NoError(t, err)
+		require.Equal(t, header.EthernetMinimumSize+len(ip), len(b))
+		require.Equal(t, header.IPv4ProtocolNumber, header.Ethernet(b).Type())
+
+		p, err := ParseFrame(b)
+		require.NoError(t, err)
+		require.Equal(t, f, p)
+	})
+
+	t.Run("8021q", func(t *testing.T) {
+		f := &Frame{
+			Dst: dst, Src: src,
+			Tags: []VLAN{{TPID: TPID8021Q, PCP: 3, ID: 10}},
+			Type: header.IPv4ProtocolNumber, Payload: ip,
+		}
+		b, err := f.Marshal()
+		require.NoError(t, err)
+		require.Equal(t, 18+len(ip), len(b))
+		require.Equal(t, []byte{0x81, 0x00, 0x60, 0x0a, 0x08, 0x00}, b[12:18])
+
+		p, err := ParseFrame(b)
+		require.NoError(t, err)
+		require.Equal(t, f, p)
+	})
+
+	t.Run("qinq", func(t *testing.T) {
+		f := &Frame{
+			Dst: dst, Src: src,
+			Tags: []VLAN{{TPID: TPID8021AD, ID: 200}, {TPID: TPID8021Q, ID: 10}},
+			Type: header.IPv6ProtocolNumber, Payload: ip,
+		}
+		b, err := f.Marshal()
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x88, 0xa8, 0x00, 0xc8, 0x81, 0x00, 0x00, 0x0a, 0x86, 0xdd}, b[12:22])
+
+		p, err := ParseFrame(b)
+		require.NoError(t, err)
+		require.Equal(t, f, p)
+	})
+
+	t.Run("default-tpid", func(t *testing.T) {
+		f := &Frame{Dst: dst, Src: src, Tags: []VLAN{{ID: 1}}, Type: header.IPv4ProtocolNumber}
+		b, err := f.Marshal()
+		require.NoError(t, err)
+
+		p, err := ParseFrame(b)
+		require.NoError(t, err)
+		require.Equal(t, TPID8021Q, p.Tags[0].TPID)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseFrame(make([]byte, 10))
+		require.Error(t, err)
+
+		b := make([]byte, 16)
+		b[12], b[13] = 0x81, 0x00
+		_, err = ParseFrame(b)
+		require.Error(t, err)
+
+		_, err = (&Frame{Dst: dst, Src: src}).Encode(make([]byte, 4))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,47 @@
+package neigh
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+type arpPacket struct {
+	op       header.ARPOp
+	senderHw net.HardwareAddr
+	sender   netip.Addr
+	targetHw net.HardwareAddr
+	target   netip.Addr
+}
+
+// gratuitous announce sender self address
+func (a *arpPacket) gratuitous() bool {
+	return a.sender == a.target
+}
+
+func buildARP(a *arpPacket) header.ARP {
+	var b = make(header.ARP, header.ARPSize)
+	b.SetIPv4OverEthernet()
+	b.SetOp(a.op)
+	copy(b.HardwareAddressSender(), a.senderHw)
+	copy(b.ProtocolAddressSender(), a.sender.AsSlice())
+	copy(b.HardwareAddressTarget(), a.targetHw)
+	copy(b.ProtocolAddressTarget(), a.target.AsSlice())
+	return b
+}
+
+func parseARP(b []byte) (*arpPacket, error) {
+	a := header.ARP(b)
+	if !a.IsValid() {
+		return nil, errors.Errorf("invalid arp packet %x", b)
+	}
+	return &arpPacket{
+		op:       a.Op(),
+		senderHw: net.HardwareAddr(a.HardwareAddressSender()),
+		sender:   netip.AddrFrom4([4]byte(a.ProtocolAddressSender())),
+		targetHw: net.HardwareAddr(a.HardwareAddressTarget()),
+		target:   netip.AddrFrom4([4]byte(a.ProtocolAddressTarget())),
+	}, nil
+}
@@ -0,0 +1,80 @@
+//go:build linux
+// +build linux
+
+package neigh
+
+import (
+	"net"
+	"net/netip"
+	"syscall"
+	"unsafe"
+
+	"github.com/lysShub/netkit/errorx"
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// KernelLookup lookup kernel neighbor table, return NotFound error if
+// without valid entry.
+func KernelLookup(ifi int, addr netip.Addr) (net.HardwareAddr, error) {
+	family := unix.AF_INET
+	if addr.Is6() {
+		family = unix.AF_INET6
+	}
+
+	tab, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, family)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for i := 0; i < len(msgs); i++ {
+		m := msgs[i]
+		switch m.Header.Type {
+		case unix.RTM_NEWNEIGH:
+			if len(m.Data) < unix.SizeofNdMsg {
+				return nil, errors.Errorf("invalid ndmsg %x", m.Data)
+			}
+			nd := (*unix.NdMsg)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+			const valid = unix.NUD_REACHABLE | unix.NUD_STALE | unix.NUD_DELAY |
+				unix.NUD_PROBE | unix.NUD_PERMANENT | unix.NUD_NOARP
+			if int(nd.Ifindex) != ifi || nd.State&valid == 0 {
+				continue
+			}
+
+			attrs, err := netcall.ParseNetlinkAttr(m.Data[unix.SizeofNdMsg:])
+			if err != nil {
+				return nil, err
+			}
+			var (
+				dst netip.Addr
+				hw  net.HardwareAddr
+			)
+			for _, e := range attrs {
+				switch e.Attr.Type {
+				case unix.NDA_DST:
+					dst, _ = netip.AddrFromSlice(e.Value)
+				case unix.NDA_LLADDR:
+					hw = net.HardwareAddr(e.Value)
+				}
+			}
+			if dst == addr && len(hw) > 0 {
+				return hw, nil
+			}
+		case unix.NLMSG_DONE:
+			i = len(msgs) // break
+		case unix.NLMSG_NOOP:
+			continue
+		case unix.NLMSG_ERROR:
+			rt := (*unix.NlMsgerr)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+			return nil, errors.WithStack(unix.Errno(-rt.Error))
+		default:
+			return nil, errors.Errorf("unexpect nlmsghdr type 0x%02x", m.Header.Type)
+		}
+	}
+	return nil, errorx.WrapNotfound(errors.Errorf("not found neighbor %s", addr.String()))
+}
@@ -0,0 +1,91 @@
+package neigh
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// buildSolicit build neighbor solicitation ipv6 packet, return the
+// packet and destination hardware address.
+func buildSolicit(src netip.Addr, hw net.HardwareAddr, target netip.Addr) (header.IPv6, net.HardwareAddr) {
+	var opts header.NDPOptionsSerializer
+	if !src.IsUnspecified() {
+		opts = append(opts, header.NDPSourceLinkLayerAddressOption(hw))
+	}
+	var (
+		tar  = tcpip.AddrFrom16(target.As16())
+		dst  = header.SolicitedNodeAddr(tar)
+		size = header.ICMPv6NeighborSolicitMinimumSize + opts.Length()
+	)
+
+	var ip = make(header.IPv6, header.IPv6MinimumSize+size)
+	ip.Encode(&header.IPv6Fields{
+		PayloadLength:     uint16(size),
+		TransportProtocol: header.ICMPv6ProtocolNumber,
+		HopLimit:          header.NDPHopLimit,
+		SrcAddr:           tcpip.AddrFrom16(src.As16()),
+		DstAddr:           dst,
+	})
+
+	icmp := header.ICMPv6(ip.Payload())
+	icmp.SetType(header.ICMPv6NeighborSolicit)
+	ns := header.NDPNeighborSolicit(icmp.MessageBody())
+	ns.SetTargetAddress(tar)
+	ns.Options().Serialize(opts)
+	icmp.SetChecksum(header.ICMPv6Checksum(header.ICMPv6ChecksumParams{
+		Header: icmp,
+		Src:    ip.SourceAddress(),
+		Dst:    dst,
+	}))
+
+	return ip, net.HardwareAddr(header.EthernetAddressFromMulticastIPv6Address(dst))
+}
+
+type advert struct {
+	target    netip.Addr
+	hw        net.HardwareAddr // target link-layer address option, maybe nil
+	solicited bool
+	override  bool
+}
+
+// parseAdvert parse neighbor advertisement ipv6 packet
+func parseAdvert(b []byte) (*advert, error) {
+	ip := header.IPv6(b)
+	if !ip.IsValid(len(b)) {
+		return nil, errors.New("invalid ipv6 packet")
+	} else if ip.TransportProtocol() != header.ICMPv6ProtocolNumber || ip.HopLimit() != header.NDPHopLimit {
+		return nil, errors.New("not ndp packet")
+	}
+
+	icmp := header.ICMPv6(ip.Payload())
+	if len(icmp) < header.ICMPv6NeighborAdvertMinimumSize || icmp.Type() != header.ICMPv6NeighborAdvert {
+		return nil, errors.New("not neighbor advertisement")
+	}
+	na := header.NDPNeighborAdvert(icmp.MessageBody())
+
+	var a = &advert{
+		target:    netip.AddrFrom16(na.TargetAddress().As16()),
+		solicited: na.SolicitedFlag(),
+		override:  na.OverrideFlag(),
+	}
+	it, err := na.Options().Iter(true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for {
+		opt, done, err := it.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		} else if done {
+			break
+		}
+		if tll, ok := opt.(header.NDPTargetLinkLayerAddressOption); ok {
+			a.hw = net.HardwareAddr(tll.EthernetAddress())
+		}
+	}
+	return a, nil
+}
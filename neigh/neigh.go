@@ -0,0 +1,119 @@
+package neigh
+
+/*
+	neighbor(ARP/NDP) resolver and cache
+*/
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/lysShub/netkit/route"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// NextHop the neighbor addr that dst packet should be sent to, it's
+// gateway of route entry, or dst self if on-link.
+func NextHop(e route.Entry, dst netip.Addr) netip.Addr {
+	if e.Next.IsValid() && !e.Next.IsUnspecified() {
+		return e.Next
+	}
+	return dst
+}
+
+// multicast get the hardware address of multicast/broadcast addr
+func multicast(addr netip.Addr) (net.HardwareAddr, bool) {
+	if addr.Is4() {
+		if addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+			return net.HardwareAddr(header.EthernetBroadcastAddress), true
+		} else if addr.IsMulticast() {
+			hw := header.EthernetAddressFromMulticastIPv4Address(tcpip.AddrFrom4(addr.As4()))
+			return net.HardwareAddr(hw), true
+		}
+	} else if addr.IsMulticast() {
+		hw := header.EthernetAddressFromMulticastIPv6Address(tcpip.AddrFrom16(addr.As16()))
+		return net.HardwareAddr(hw), true
+	}
+	return nil, false
+}
+
+// cache neighbor cache with aging
+type cache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[netip.Addr]cacheEntry
+}
+
+type cacheEntry struct {
+	hw     net.HardwareAddr
+	expire time.Time // zero means permanent
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl:     ttl,
+		entries: map[netip.Addr]cacheEntry{},
+	}
+}
+
+func (c *cache) Get(addr netip.Addr) (net.HardwareAddr, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, has := c.entries[addr]
+	if !has || (!e.expire.IsZero() && time.Now().After(e.expire)) {
+		return nil, false
+	}
+	return e.hw, true
+}
+
+// Set set entry, permanent entry never aging
+func (c *cache) Set(addr netip.Addr, hw net.HardwareAddr, permanent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := cacheEntry{hw: hw}
+	if !permanent {
+		if old, has := c.entries[addr]; has && old.expire.IsZero() {
+			return // not overwrite permanent entry
+		}
+		e.expire = time.Now().Add(c.ttl)
+	}
+	c.entries[addr] = e
+}
+
+// Update update exist entry, return false if not exist
+func (c *cache) Update(addr netip.Addr, hw net.HardwareAddr) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, has := c.entries[addr]
+	if !has {
+		return false
+	} else if !e.expire.IsZero() {
+		c.entries[addr] = cacheEntry{hw: hw, expire: time.Now().Add(c.ttl)}
+	}
+	return true
+}
+
+func (c *cache) Delete(addr netip.Addr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, addr)
+}
+
+// Purge delete expired entries
+func (c *cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range c.entries {
+		if !e.expire.IsZero() && now.After(e.expire) {
+			delete(c.entries, k)
+		}
+	}
+}
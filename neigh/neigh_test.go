@@ -0,0 +1,161 @@
+package neigh
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/lysShub/netkit/route"
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+func Test_NextHop(t *testing.T) {
+	dst := netip.MustParseAddr("8.8.8.8")
+
+	e := route.Entry{Next: netip.MustParseAddr("192.168.0.1")}
+	require.Equal(t, e.Next, NextHop(e, dst))
+
+	e = route.Entry{}
+	require.Equal(t, dst, NextHop(e, dst))
+}
+
+func Test_Multicast(t *testing.T) {
+	hw, ok := multicast(netip.MustParseAddr("255.255.255.255"))
+	require.True(t, ok)
+	require.Equal(t, "ff:ff:ff:ff:ff:ff", hw.String())
+
+	hw, ok = multicast(netip.MustParseAddr("224.0.0.251"))
+	require.True(t, ok)
+	require.Equal(t, "01:00:5e:00:00:fb", hw.String())
+
+	hw, ok = multicast(netip.MustParseAddr("ff02::1:ff00:1"))
+	require.True(t, ok)
+	require.Equal(t, "33:33:ff:00:00:01", hw.String())
+
+	_, ok = multicast(netip.MustParseAddr("10.0.0.1"))
+	require.False(t, ok)
+}
+
+func Test_Cache(t *testing.T) {
+	var (
+		addr = netip.MustParseAddr("10.0.0.1")
+		hw1  = net.HardwareAddr{0, 1, 2, 3, 4, 5}
+		hw2  = net.HardwareAddr{0, 1, 2, 3, 4, 6}
+	)
+
+	t.Run("aging", func(t *testing.T) {
+		c := newCache(time.Millisecond * 50)
+		require.False(t, c.Update(addr, hw1))
+
+		c.Set(addr, hw1, false)
+		hw, ok := c.Get(addr)
+		require.True(t, ok)
+		require.Equal(t, hw1, hw)
+
+		require.True(t, c.Update(addr, hw2))
+		hw, ok = c.Get(addr)
+		require.True(t, ok)
+		require.Equal(t, hw2, hw)
+
+		time.Sleep(time.Millisecond * 100)
+		_, ok = c.Get(addr)
+		require.False(t, ok)
+
+		c.Purge()
+		require.Zero(t, len(c.entries))
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		c := newCache(time.Millisecond * 50)
+		c.Set(addr, hw1, true)
+		c.Set(addr, hw2, false)
+		require.True(t, c.Update(addr, hw2))
+
+		time.Sleep(time.Millisecond * 100)
+		c.Purge()
+		hw, ok := c.Get(addr)
+		require.True(t, ok)
+		require.Equal(t, hw1, hw)
+
+		c.Delete(addr)
+		_, ok = c.Get(addr)
+		require.False(t, ok)
+	})
+}
+
+func Test_ARP(t *testing.T) {
+	a := &arpPacket{
+		op:       header.ARPReply,
+		senderHw: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		sender:   netip.MustParseAddr("10.0.0.1"),
+		targetHw: net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		target:   netip.MustParseAddr("10.0.0.2"),
+	}
+	b := buildARP(a)
+	require.Equal(t, header.ARPSize, len(b))
+
+	p, err := parseARP(append(b, make([]byte, 18)...)) // with padding
+	require.NoError(t, err)
+	require.Equal(t, a, p)
+	require.False(t, p.gratuitous())
+
+	_, err = parseARP(b[:20])
+	require.Error(t, err)
+}
+
+func Test_NDP(t *testing.T) {
+	var (
+		src    = netip.MustParseAddr("fe80::1")
+		hw     = net.HardwareAddr{0, 1, 2, 3, 4, 5}
+		target = netip.MustParseAddr("fe80::1234:5678")
+	)
+
+	t.Run("solicit", func(t *testing.T) {
+		ip, dst := buildSolicit(src, hw, target)
+		require.Equal(t, "33:33:ff:34:56:78", dst.String())
+		require.True(t, ip.IsValid(len(ip)))
+		require.Equal(t, "ff02::1:ff34:5678", ip.DestinationAddress().String())
+
+		icmp := header.ICMPv6(ip.Payload())
+		require.Equal(t, header.ICMPv6NeighborSolicit, icmp.Type())
+		sum := header.PseudoHeaderChecksum(
+			header.ICMPv6ProtocolNumber, ip.SourceAddress(), ip.DestinationAddress(), uint16(len(icmp)),
+		)
+		require.Equal(t, uint16(0xffff), checksum.Checksum(icmp, sum))
+		ns := header.NDPNeighborSolicit(icmp.MessageBody())
+		require.Equal(t, target.String(), ns.TargetAddress().String())
+	})
+
+	t.Run("advert", func(t *testing.T) {
+		opts := header.NDPOptionsSerializer{header.NDPTargetLinkLayerAddressOption(hw)}
+		size := header.ICMPv6NeighborAdvertMinimumSize + opts.Length()
+		ip := make(header.IPv6, header.IPv6MinimumSize+size)
+		ip.Encode(&header.IPv6Fields{
+			PayloadLength:     uint16(size),
+			TransportProtocol: header.ICMPv6ProtocolNumber,
+			HopLimit:          header.NDPHopLimit,
+			SrcAddr:           tcpip.AddrFrom16(target.As16()),
+			DstAddr:           tcpip.AddrFrom16(src.As16()),
+		})
+		icmp := header.ICMPv6(ip.Payload())
+		icmp.SetType(header.ICMPv6NeighborAdvert)
+		na := header.NDPNeighborAdvert(icmp.MessageBody())
+		na.SetTargetAddress(tcpip.AddrFrom16(target.As16()))
+		na.SetSolicitedFlag(true)
+		na.Options().Serialize(opts)
+
+		a, err := parseAdvert(ip)
+		require.NoError(t, err)
+		require.Equal(t, target, a.target)
+		require.Equal(t, hw, a.hw)
+		require.True(t, a.solicited)
+
+		ip.SetHopLimit(64)
+		_, err = parseAdvert(ip)
+		require.Error(t, err)
+	})
+}
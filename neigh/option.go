@@ -0,0 +1,52 @@
+package neigh
+
+import "time"
+
+type Option func(*Configs)
+
+func Options(opts ...Option) *Configs {
+	cfg := &Configs{
+		ttl:      time.Second * 30,
+		retry:    3,
+		interval: time.Second,
+		kernel:   true,
+	}
+	for _, e := range opts {
+		e(cfg)
+	}
+	return cfg
+}
+
+// TTL aging time of cache entry
+func TTL(ttl time.Duration) Option {
+	return func(c *Configs) {
+		c.ttl = ttl
+	}
+}
+
+// Retry send request times and interval before fallback to kernel neighbor table
+func Retry(times int, interval time.Duration) Option {
+	return func(c *Configs) {
+		c.retry = max(times, 1)
+		c.interval = interval
+	}
+}
+
+// AcceptGratuitous create cache entry by gratuitous ARP/unsolicited NA, default
+// only update exist entry, same as linux arp_accept=0.
+func AcceptGratuitous(c *Configs) {
+	c.gratuitous = true
+}
+
+// NotKernel not fallback to kernel neighbor table
+func NotKernel(c *Configs) {
+	c.kernel = false
+}
+
+type Configs struct {
+	ttl        time.Duration
+	retry      int
+	interval   time.Duration
+	gratuitous bool
+	kernel     bool
+}
@@ -0,0 +1,336 @@
+//go:build linux
+// +build linux
+
+package neigh
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/lysShub/netkit/errorx"
+	"github.com/lysShub/netkit/eth"
+	"github.com/lysShub/netkit/route"
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/net/bpf"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// Resolver resolve neighbor hardware address of an ethernet interface,
+// by ARP(ipv4) and NDP(ipv6).
+type Resolver struct {
+	ifi   *net.Interface
+	cfg   *Configs
+	cache *cache
+
+	arp, ndp *eth.ETHConn
+
+	mu      sync.Mutex
+	waiters map[netip.Addr]chan struct{}
+	addrs   []netip.Prefix // cache of interface addresses
+	addrsAt time.Time
+
+	closed   chan struct{}
+	closeErr errorx.CloseErr
+}
+
+func New(ifi *net.Interface, opts ...Option) (*Resolver, error) {
+	if len(ifi.HardwareAddr) != 6 {
+		return nil, errors.Errorf("interface %s without ethernet address", ifi.Name)
+	}
+
+	var r = &Resolver{
+		ifi:     ifi,
+		cfg:     Options(opts...),
+		waiters: map[netip.Addr]chan struct{}{},
+		closed:  make(chan struct{}),
+	}
+	r.cache = newCache(r.cfg.ttl)
+
+	var err error
+	if r.arp, err = eth.Listen("eth:arp", ifi); err != nil {
+		return nil, r.close(err)
+	}
+	if r.ndp, err = eth.Listen("eth:ip6", ifi); err != nil {
+		return nil, r.close(err)
+	}
+	if err = netcall.SetRawConnBPF(r.ndp, advertFilter); err != nil {
+		return nil, r.close(err)
+	}
+
+	go r.arpService()
+	go r.ndpService()
+	return r, nil
+}
+
+// advertFilter only accept icmpv6 neighbor advertisement(without extension header)
+var advertFilter = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 6, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(header.ICMPv6ProtocolNumber), SkipTrue: 3},
+	bpf.LoadAbsolute{Off: header.IPv6MinimumSize, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(header.ICMPv6NeighborAdvert), SkipTrue: 1},
+	bpf.RetConstant{Val: 0xffff},
+	bpf.RetConstant{Val: 0},
+}
+
+func (r *Resolver) close(cause error) error {
+	return r.closeErr.Close(func() (errs []error) {
+		errs = append(errs, cause)
+		close(r.closed)
+		if r.arp != nil {
+			errs = append(errs, r.arp.Close())
+		}
+		if r.ndp != nil {
+			errs = append(errs, r.ndp.Close())
+		}
+		return errs
+	})
+}
+
+func (r *Resolver) arpService() {
+	var b = make([]byte, 1536)
+	for {
+		n, _, err := r.arp.ReadFromETH(b)
+		if err != nil {
+			if errorx.IsTemporary(err) {
+				continue
+			}
+			r.close(err)
+			return
+		}
+
+		a, err := parseARP(b[:n])
+		if err != nil {
+			continue
+		}
+		switch {
+		case a.gratuitous():
+			r.learn(a.sender, a.senderHw, r.cfg.gratuitous)
+		case a.op == header.ARPReply:
+			// unsolicited reply only update exist entry, same as gratuitous
+			r.learn(a.sender, a.senderHw, r.cfg.gratuitous)
+		case a.op == header.ARPRequest && r.local(a.target):
+			r.learn(a.sender, a.senderHw, false)
+		}
+	}
+}
+
+func (r *Resolver) ndpService() {
+	var b = make([]byte, 1536)
+	for {
+		n, from, err := r.ndp.ReadFromETH(b)
+		if err != nil {
+			if errorx.IsTemporary(err) {
+				continue
+			}
+			r.close(err)
+			return
+		}
+
+		a, err := parseAdvert(b[:n])
+		if err != nil {
+			continue
+		}
+		hw := a.hw
+		if len(hw) == 0 {
+			hw = from
+		}
+		r.learn(a.target, hw, a.solicited || r.cfg.gratuitous)
+	}
+}
+
+// learn update cache, create new entry only if create is true or the addr is resolving
+func (r *Resolver) learn(addr netip.Addr, hw net.HardwareAddr, create bool) {
+	if len(hw) != 6 || !addr.IsValid() || addr.IsUnspecified() {
+		return
+	}
+	hw = append(net.HardwareAddr{}, hw...)
+
+	r.mu.Lock()
+	ch, resolving := r.waiters[addr]
+	if resolving {
+		delete(r.waiters, addr)
+	}
+	r.mu.Unlock()
+
+	if create || resolving {
+		r.cache.Set(addr, hw, false)
+	} else {
+		r.cache.Update(addr, hw)
+	}
+	if resolving {
+		close(ch)
+	}
+}
+
+func (r *Resolver) Interface() *net.Interface { return r.ifi }
+
+// Resolve resolve neighbor hardware address, fallback to kernel neighbor table
+// if not reply.
+func (r *Resolver) Resolve(ctx context.Context, addr netip.Addr) (net.HardwareAddr, error) {
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsValid() || addr.IsUnspecified() {
+		return nil, errors.Errorf("invalid address %s", addr.String())
+	} else if hw, ok := multicast(addr); ok {
+		return hw, nil
+	} else if r.local(addr) {
+		return r.ifi.HardwareAddr, nil
+	} else if hw, ok := r.cache.Get(addr); ok {
+		return hw, nil
+	}
+
+	var ch chan struct{}
+	defer func() {
+		r.mu.Lock()
+		if ch != nil && r.waiters[addr] == ch {
+			delete(r.waiters, addr)
+		}
+		r.mu.Unlock()
+	}()
+	for i := 0; i < r.cfg.retry; i++ {
+		ch = r.waiter(addr)
+		if err := r.request(addr); err != nil {
+			return nil, err
+		}
+
+		timer := time.NewTimer(r.cfg.interval)
+		select {
+		case <-ch:
+			timer.Stop()
+			if hw, ok := r.cache.Get(addr); ok {
+				return hw, nil
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.WithStack(ctx.Err())
+		case <-r.closed:
+			timer.Stop()
+			return nil, r.closeErr.Error()
+		}
+	}
+
+	if r.cfg.kernel {
+		if hw, err := KernelLookup(r.ifi.Index, addr); err == nil {
+			r.cache.Set(addr, hw, false)
+			return hw, nil
+		}
+	}
+	return nil, errorx.WrapNotfound(errors.Errorf("can't resolve %s on %s", addr.String(), r.ifi.Name))
+}
+
+// ResolveRoute resolve the next hop hardware address of dst, the route entry
+// matched from table must use the resolver's interface.
+func (r *Resolver) ResolveRoute(ctx context.Context, table route.Table, dst netip.Addr) (net.HardwareAddr, route.Entry, error) {
+	e := table.Match(dst)
+	if !e.Valid() {
+		return nil, route.Entry{}, errorx.WrapNotfound(errors.Errorf("not route to %s", dst.String()))
+	} else if int(e.Interface) != r.ifi.Index {
+		return nil, e, errors.Errorf("route %s not through interface %s", e.Dest.String(), r.ifi.Name)
+	}
+
+	hw, err := r.Resolve(ctx, NextHop(e, dst))
+	return hw, e, err
+}
+
+// Set set a permanent entry
+func (r *Resolver) Set(addr netip.Addr, hw net.HardwareAddr) {
+	r.cache.Set(addr.Unmap(), append(net.HardwareAddr{}, hw...), true)
+}
+
+func (r *Resolver) Delete(addr netip.Addr) { r.cache.Delete(addr.Unmap()) }
+
+// Purge delete expired cache entries
+func (r *Resolver) Purge() { r.cache.Purge() }
+
+func (r *Resolver) Close() error { return r.close(nil) }
+
+func (r *Resolver) waiter(addr netip.Addr) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch, has := r.waiters[addr]
+	if !has {
+		ch = make(chan struct{})
+		r.waiters[addr] = ch
+	}
+	return ch
+}
+
+func (r *Resolver) request(addr netip.Addr) error {
+	src := r.source(addr)
+	if addr.Is4() {
+		if !src.IsValid() {
+			src = netip.IPv4Unspecified() // arp probe
+		}
+		req := buildARP(&arpPacket{
+			op:       header.ARPRequest,
+			senderHw: r.ifi.HardwareAddr,
+			sender:   src,
+			targetHw: make(net.HardwareAddr, 6),
+			target:   addr,
+		})
+		_, err := r.arp.WriteToETH(req, net.HardwareAddr(header.EthernetBroadcastAddress))
+		return err
+	} else {
+		if !src.IsValid() {
+			src = netip.IPv6Unspecified()
+		}
+		ip, dst := buildSolicit(src, r.ifi.HardwareAddr, addr)
+		_, err := r.ndp.WriteToETH(ip, dst)
+		return err
+	}
+}
+
+// source select request source address, prefer the address in same subnet
+func (r *Resolver) source(dst netip.Addr) (src netip.Addr) {
+	for _, e := range r.ifAddrs() {
+		addr := e.Addr()
+		if addr.Is4() != dst.Is4() {
+			continue
+		}
+		if e.Contains(dst) {
+			return addr
+		} else if !src.IsValid() || (dst.IsLinkLocalUnicast() == addr.IsLinkLocalUnicast()) {
+			src = addr
+		}
+	}
+	return src
+}
+
+// local detect addr is the interface's address
+func (r *Resolver) local(addr netip.Addr) bool {
+	for _, e := range r.ifAddrs() {
+		if e.Addr() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// addrsTTL refresh interval of interface addresses cache
+const addrsTTL = time.Second * 5
+
+// ifAddrs addresses of the interface, cached for addrsTTL, avoid netlink
+// dump for every received packet.
+func (r *Resolver) ifAddrs() []netip.Prefix {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.addrsAt) < addrsTTL {
+		return r.addrs
+	}
+
+	addrs, err := netcall.GetIfAddrs(r.ifi.Index)
+	if err != nil {
+		return r.addrs // keep stale, retry next time
+	}
+	r.addrs = r.addrs[:0:0]
+	for _, e := range addrs {
+		r.addrs = append(r.addrs, netip.PrefixFrom(e.Prefix.Addr().WithZone(""), e.Prefix.Bits()))
+	}
+	r.addrsAt = time.Now()
+	return r.addrs
+}
@@ -0,0 +1,153 @@
+//go:build linux
+// +build linux
+
+package neigh_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/lysShub/netkit/neigh"
+	"github.com/lysShub/netkit/route"
+	"github.com/lysShub/netkit/tun"
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+func Test_Resolve_Gateway(t *testing.T) {
+	table, err := route.GetTable()
+	require.NoError(t, err)
+	var e route.Entry
+	for _, e1 := range table {
+		if e1.Next.IsValid() {
+			e = e1
+			break
+		}
+	}
+	if !e.Next.IsValid() {
+		t.Skip("no default gateway")
+	}
+
+	ifi, err := net.InterfaceByIndex(int(e.Interface))
+	require.NoError(t, err)
+
+	r, err := neigh.New(ifi, neigh.NotKernel)
+	require.NoError(t, err)
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	hw, err := r.Resolve(ctx, e.Next)
+	require.NoError(t, err)
+	require.Equal(t, 6, len(hw))
+
+	khw, err := neigh.KernelLookup(ifi.Index, e.Next)
+	require.NoError(t, err)
+	require.Equal(t, khw, hw)
+
+	hw1, _, err := r.ResolveRoute(ctx, table, e.Next)
+	require.NoError(t, err)
+	require.Equal(t, hw, hw1)
+}
+
+func Test_Resolve_Closed(t *testing.T) {
+	ap, err := tun.Tap("testneighclose")
+	require.NoError(t, err)
+	defer ap.Close()
+	ifi, err := net.InterfaceByName("testneighclose")
+	require.NoError(t, err)
+
+	r, err := neigh.New(ifi)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = r.Resolve(context.Background(), netip.MustParseAddr("10.255.255.254"))
+	require.Error(t, err)
+}
+
+func Test_Resolve_Cancel(t *testing.T) {
+	ap, err := tun.Tap("testneighcancel")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.40.1/24")))
+	ifi, err := net.InterfaceByName("testneighcancel")
+	require.NoError(t, err)
+
+	r, err := neigh.New(ifi, neigh.NotKernel, neigh.Retry(3, time.Second))
+	require.NoError(t, err)
+	defer r.Close()
+
+	hw, err := r.Resolve(context.Background(), netip.MustParseAddr("10.0.40.1"))
+	require.NoError(t, err)
+	require.Equal(t, ifi.HardwareAddr, hw)
+
+	// the waiter of cancelled resolve is removed, resolve again not use the
+	// closed channel
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		s := time.Now()
+		_, err = r.Resolve(ctx, netip.MustParseAddr("10.0.40.2"))
+		cancel()
+		require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+		require.Less(t, time.Since(s), time.Millisecond*500)
+	}
+}
+
+func Test_Resolve_Unsolicited(t *testing.T) {
+	ap, err := tun.Tap("testneighreply")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.41.1/24")))
+	ifi, err := net.InterfaceByName("testneighreply")
+	require.NoError(t, err)
+
+	var (
+		peer   = netip.MustParseAddr("10.0.41.2")
+		peerHw = net.HardwareAddr{0x02, 0, 0, 0, 0x41, 0x02}
+	)
+	reply := func() {
+		var b = make([]byte, header.EthernetMinimumSize+header.ARPSize)
+		header.Ethernet(b).Encode(&header.EthernetFields{
+			SrcAddr: tcpip.LinkAddress(peerHw),
+			DstAddr: tcpip.LinkAddress(ifi.HardwareAddr),
+			Type:    header.ARPProtocolNumber,
+		})
+		a := header.ARP(b[header.EthernetMinimumSize:])
+		a.SetIPv4OverEthernet()
+		a.SetOp(header.ARPReply)
+		copy(a.HardwareAddressSender(), peerHw)
+		copy(a.ProtocolAddressSender(), peer.AsSlice())
+		copy(a.HardwareAddressTarget(), ifi.HardwareAddr)
+		copy(a.ProtocolAddressTarget(), []byte{10, 0, 41, 1})
+		_, err := ap.Write(context.Background(), b)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	t.Run("ignore", func(t *testing.T) {
+		r, err := neigh.New(ifi, neigh.NotKernel, neigh.Retry(1, time.Millisecond*100))
+		require.NoError(t, err)
+		defer r.Close()
+
+		reply()
+		_, err = r.Resolve(context.Background(), peer)
+		require.Error(t, err)
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		r, err := neigh.New(ifi, neigh.NotKernel, neigh.Retry(1, time.Millisecond*100), neigh.AcceptGratuitous)
+		require.NoError(t, err)
+		defer r.Close()
+
+		reply()
+		hw, err := r.Resolve(context.Background(), peer)
+		require.NoError(t, err)
+		require.Equal(t, peerHw, hw)
+	})
+}
@@ -0,0 +1,172 @@
+package offload
+
+import (
+	"bytes"
+
+	"github.com/lysShub/netkit/packet"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// GRO coalesce consecutive tcp segments of the same flow into one large packet,
+// such as:
+//
+//	g := offload.NewGRO(offload.MaxSize)
+//	for _, p := range pkts {
+//		g.Add(p)
+//	}
+//	for _, e := range g.Flush() {
+//		// write e.Packet with e.GSOSize
+//	}
+//
+// not tcp or can't coalesced packet be passed through in order.
+type GRO struct {
+	max   int
+	items []Item
+	flows map[flow]int // index of items, the flow can be coalesced
+}
+
+// Item coalesced packet
+type Item struct {
+	*packet.Packet
+
+	// GSOSize payload size of the coalesced segments, the last segment maybe
+	// less than it, 0 means not coalesced.
+	GSOSize int
+
+	info     ipinfo
+	segs     int
+	nextSeq  uint32
+	psh, fin bool
+}
+
+type flow struct {
+	src, dst   tcpip.Address
+	sport, dpt uint16
+}
+
+// NewGRO size is max size of coalesced ip packet, not greater than MaxSize
+func NewGRO(size int) *GRO {
+	if size <= 0 || size > MaxSize {
+		size = MaxSize
+	}
+	return &GRO{max: size, flows: map[flow]int{}}
+}
+
+// Add add ip packet, p maybe be appended to prior packet of the same flow, the
+// caller should not use p after Add.
+func (g *GRO) Add(p *packet.Packet) {
+	ip := p.Bytes()
+	info, err := parse(ip)
+	if err != nil || info.proto != header.TCPProtocolNumber {
+		g.items = append(g.items, Item{Packet: p})
+		return
+	}
+
+	var (
+		tcphdr  = header.TCP(ip[info.l4:])
+		payload = len(ip) - info.hdrLen
+		flags   = tcphdr.Flags()
+		key     = flow{
+			src: info.src, dst: info.dst,
+			sport: tcphdr.SourcePort(), dpt: tcphdr.DestinationPort(),
+		}
+	)
+	if i, has := g.flows[key]; has {
+		if g.coalesce(&g.items[i], p, &info) {
+			if g.items[i].psh || g.items[i].fin || payload < g.items[i].GSOSize {
+				delete(g.flows, key) // flow ended, or short segment
+			}
+			return
+		}
+		delete(g.flows, key)
+	}
+
+	var item = Item{
+		Packet:  p,
+		info:    info,
+		segs:    1,
+		nextSeq: tcphdr.SequenceNumber() + uint32(payload),
+		GSOSize: payload,
+		psh:     flags.Contains(header.TCPFlagPsh),
+		fin:     flags.Contains(header.TCPFlagFin),
+	}
+	const ctrl = header.TCPFlagSyn | header.TCPFlagRst | header.TCPFlagUrg | header.TCPFlagFin
+	if payload > 0 && !item.psh && flags&ctrl == 0 {
+		g.flows[key] = len(g.items)
+	}
+	g.items = append(g.items, item)
+}
+
+func (g *GRO) coalesce(item *Item, p *packet.Packet, info *ipinfo) bool {
+	var (
+		ip      = p.Bytes()
+		dst     = item.Bytes()
+		tcphdr  = header.TCP(ip[info.l4:])
+		dsthdr  = header.TCP(dst[item.info.l4:])
+		payload = len(ip) - info.hdrLen
+	)
+	if payload == 0 || payload > item.GSOSize ||
+		len(dst)+payload > g.max ||
+		tcphdr.SequenceNumber() != item.nextSeq ||
+		tcphdr.AckNumber() != dsthdr.AckNumber() ||
+		info.hdrLen != item.info.hdrLen || info.ipv4 != item.info.ipv4 {
+		return false
+	}
+
+	const allow = header.TCPFlagAck | header.TCPFlagPsh | header.TCPFlagFin
+	flags := tcphdr.Flags()
+	if flags&^allow != dsthdr.Flags()&^allow&^header.TCPFlagCwr || flags&header.TCPFlagCwr != 0 {
+		return false
+	}
+
+	// tcp options and ip header must same, except length, id and checksum
+	if !bytes.Equal(ip[info.l4+header.TCPMinimumSize:info.hdrLen], dst[item.info.l4+header.TCPMinimumSize:item.info.hdrLen]) {
+		return false
+	}
+	if info.ipv4 {
+		a, b := header.IPv4(ip), header.IPv4(dst)
+		ta, _ := a.TOS()
+		tb, _ := b.TOS()
+		if ta != tb || a.TTL() != b.TTL() || a.Flags() != b.Flags() ||
+			info.l4 != header.IPv4MinimumSize || item.info.l4 != header.IPv4MinimumSize {
+			return false
+		}
+	} else {
+		a, b := header.IPv6(ip), header.IPv6(dst)
+		ta, fa := a.TOS()
+		tb, fb := b.TOS()
+		if ta != tb || fa != fb || a.HopLimit() != b.HopLimit() {
+			return false
+		}
+	}
+
+	item.Append(ip[info.hdrLen:]...)
+	dsthdr = header.TCP(item.Bytes()[item.info.l4:])
+	dsthdr.SetWindowSize(tcphdr.WindowSize())
+	if flags&(header.TCPFlagPsh|header.TCPFlagFin) != 0 {
+		dsthdr.SetFlags(uint8(dsthdr.Flags() | flags&(header.TCPFlagPsh|header.TCPFlagFin)))
+	}
+
+	item.segs++
+	item.nextSeq += uint32(payload)
+	item.psh = flags.Contains(header.TCPFlagPsh)
+	item.fin = flags.Contains(header.TCPFlagFin)
+	return true
+}
+
+// Flush return coalesced packets in order, and reset GRO
+func (g *GRO) Flush() []Item {
+	items := g.items
+	for i := range items {
+		if items[i].segs > 1 {
+			items[i].info.fixup(items[i].Bytes())
+		} else {
+			items[i].GSOSize = 0
+		}
+	}
+
+	g.items = nil
+	clear(g.flows)
+	return items
+}
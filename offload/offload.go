@@ -0,0 +1,99 @@
+// Package offload implement userspace GSO segmentation and GRO coalescing of
+// tcp/udp ip packet, usually used with IFF_VNET_HDR tun device or gvisor stack.
+package offload
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// MaxSize max size of coalesced packet
+const MaxSize = 0xffff
+
+// ipinfo parsed ip packet
+type ipinfo struct {
+	ipv4     bool
+	proto    tcpip.TransportProtocolNumber
+	src, dst tcpip.Address
+
+	l4     int // transport header offset
+	hdrLen int // ip + transport header size
+}
+
+func parse(ip []byte) (info ipinfo, err error) {
+	switch header.IPVersion(ip) {
+	case 4:
+		iphdr := header.IPv4(ip)
+		if !iphdr.IsValid(len(ip)) || int(iphdr.TotalLength()) != len(ip) {
+			return ipinfo{}, errors.New("invalid ipv4 packet")
+		} else if iphdr.More() || iphdr.FragmentOffset() != 0 {
+			return ipinfo{}, errors.New("not support ipv4 fragment")
+		}
+		info.ipv4, info.proto, info.l4 = true, iphdr.TransportProtocol(), int(iphdr.HeaderLength())
+		info.src, info.dst = iphdr.SourceAddress(), iphdr.DestinationAddress()
+	case 6:
+		iphdr := header.IPv6(ip)
+		if !iphdr.IsValid(len(ip)) || int(iphdr.PayloadLength())+header.IPv6MinimumSize != len(ip) {
+			return ipinfo{}, errors.New("invalid ipv6 packet")
+		}
+		// todo: support extension header
+		info.proto, info.l4 = iphdr.TransportProtocol(), header.IPv6MinimumSize
+		info.src, info.dst = iphdr.SourceAddress(), iphdr.DestinationAddress()
+	default:
+		return ipinfo{}, errors.Errorf("invalid ip packet version %d", header.IPVersion(ip))
+	}
+
+	switch info.proto {
+	case header.TCPProtocolNumber:
+		if info.l4+header.TCPMinimumSize > len(ip) {
+			return ipinfo{}, errors.New("invalid tcp packet")
+		}
+		info.hdrLen = info.l4 + int(header.TCP(ip[info.l4:]).DataOffset())
+		if info.hdrLen < info.l4+header.TCPMinimumSize {
+			return ipinfo{}, errors.New("invalid tcp packet")
+		}
+	case header.UDPProtocolNumber:
+		info.hdrLen = info.l4 + header.UDPMinimumSize
+	default:
+		return ipinfo{}, errors.Errorf("not support transport protocol %d", info.proto)
+	}
+	if info.hdrLen > len(ip) {
+		return ipinfo{}, errors.Errorf("invalid %d packet", info.proto)
+	}
+	return info, nil
+}
+
+// fixup update ip header length fields and recalculate checksums
+func (info *ipinfo) fixup(ip []byte) {
+	if info.ipv4 {
+		iphdr := header.IPv4(ip)
+		iphdr.SetTotalLength(uint16(len(iphdr)))
+		iphdr.SetChecksum(0)
+		iphdr.SetChecksum(^iphdr.CalculateChecksum())
+	} else {
+		header.IPv6(ip).SetPayloadLength(uint16(len(ip) - header.IPv6MinimumSize))
+	}
+
+	l4 := ip[info.l4:]
+	var off int
+	if info.proto == header.TCPProtocolNumber {
+		off = header.TCPChecksumOffset
+	} else {
+		header.UDP(l4).SetLength(uint16(len(l4)))
+		off = udpChecksumOffset
+	}
+	binary.BigEndian.PutUint16(l4[off:], 0)
+
+	sum := header.PseudoHeaderChecksum(info.proto, info.src, info.dst, uint16(len(l4)))
+	sum = ^checksum.Checksum(l4, sum)
+	if sum == 0 && info.proto == header.UDPProtocolNumber {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(l4[off:], sum)
+}
+
+const udpChecksumOffset = 6
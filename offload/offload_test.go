@@ -0,0 +1,148 @@
+package offload_test
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/lysShub/netkit/offload"
+	"github.com/lysShub/netkit/packet"
+	"github.com/lysShub/netkit/test"
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+var (
+	saddr4 = netip.MustParseAddrPort("10.0.0.1:19986")
+	daddr4 = netip.MustParseAddrPort("10.0.0.2:8080")
+	saddr6 = netip.MustParseAddrPort("[fd00::1]:19986")
+	daddr6 = netip.MustParseAddrPort("[fd00::2]:8080")
+)
+
+func build(t *testing.T, src, dst netip.AddrPort, proto tcpip.TransportProtocolNumber, seq uint32, flags header.TCPFlags, payload []byte) *packet.Packet {
+	ip := test.BuildIP(t, src, dst, proto, seq, flags, payload)
+	p := packet.Make(16, len(ip))
+	copy(p.Bytes(), ip)
+	return p
+}
+
+func data(n int) []byte {
+	var b = make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func Test_Segment(t *testing.T) {
+	for _, addrs := range [][2]netip.AddrPort{{saddr4, daddr4}, {saddr6, daddr6}} {
+		t.Run(addrs[0].Addr().String(), func(t *testing.T) {
+			for _, proto := range []tcpip.TransportProtocolNumber{header.TCPProtocolNumber, header.UDPProtocolNumber} {
+				p := build(t, addrs[0], addrs[1], proto, 100, header.TCPFlagAck|header.TCPFlagPsh, data(3000))
+
+				segs, err := offload.Segment(p, 1400)
+				require.NoError(t, err)
+				require.Len(t, segs, 3)
+
+				var payload []byte
+				for i, e := range segs {
+					require.Equal(t, p.Head(), e.Head())
+					test.ValidIP(t, e.Bytes())
+
+					var net header.Network = header.IPv6(e.Bytes())
+					if addrs[0].Addr().Is4() {
+						net = header.IPv4(e.Bytes())
+						require.Equal(t, uint16(1+i), header.IPv4(e.Bytes()).ID())
+					}
+					if proto == header.TCPProtocolNumber {
+						tcp := header.TCP(net.Payload())
+						require.Equal(t, uint32(100+1400*i), tcp.SequenceNumber())
+						require.Equal(t, i == 2, tcp.Flags().Contains(header.TCPFlagPsh))
+						payload = append(payload, tcp.Payload()...)
+					} else {
+						payload = append(payload, header.UDP(net.Payload()).Payload()...)
+					}
+				}
+				require.Equal(t, data(3000), payload)
+			}
+		})
+	}
+
+	t.Run("small", func(t *testing.T) {
+		p := build(t, saddr4, daddr4, header.TCPProtocolNumber, 100, header.TCPFlagAck, data(100))
+		segs, err := offload.Segment(p, 1400)
+		require.NoError(t, err)
+		require.Len(t, segs, 1)
+		require.Equal(t, p, segs[0])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := offload.Segment(packet.From(make([]byte, 40)), 1400)
+		require.Error(t, err)
+	})
+}
+
+func Test_GRO(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		for _, addrs := range [][2]netip.AddrPort{{saddr4, daddr4}, {saddr6, daddr6}} {
+			p := build(t, addrs[0], addrs[1], header.TCPProtocolNumber, 100, header.TCPFlagAck|header.TCPFlagPsh, data(5000))
+			exp := append([]byte{}, p.Bytes()...)
+
+			segs, err := offload.Segment(p, 1400)
+			require.NoError(t, err)
+
+			g := offload.NewGRO(offload.MaxSize)
+			for _, e := range segs {
+				g.Add(e)
+			}
+			items := g.Flush()
+			require.Len(t, items, 1)
+			require.Equal(t, 1400, items[0].GSOSize)
+			require.True(t, bytes.Equal(exp, items[0].Bytes()))
+		}
+	})
+
+	t.Run("interleave", func(t *testing.T) {
+		g := offload.NewGRO(offload.MaxSize)
+		g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, 100, header.TCPFlagAck, data(1000)))
+		g.Add(build(t, saddr6, daddr6, header.TCPProtocolNumber, 100, header.TCPFlagAck, data(1000)))
+		g.Add(build(t, saddr4, daddr4, header.UDPProtocolNumber, 0, 0, data(1000)))
+		g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, 1100, header.TCPFlagAck, data(1000)))
+		g.Add(build(t, saddr6, daddr6, header.TCPProtocolNumber, 1100, header.TCPFlagAck, data(500)))
+		g.Add(build(t, saddr6, daddr6, header.TCPProtocolNumber, 1600, header.TCPFlagAck, data(500)))
+
+		items := g.Flush()
+		require.Len(t, items, 4)
+		require.Equal(t, 1000, items[0].GSOSize)
+		require.Equal(t, 2000+40, items[0].Data())
+		require.Equal(t, 1000, items[1].GSOSize)
+		require.Equal(t, 1500+60, items[1].Data())
+		require.Equal(t, 0, items[2].GSOSize)
+		require.Equal(t, 0, items[3].GSOSize) // after short segment
+		for _, e := range items {
+			test.ValidIP(t, e.Bytes())
+		}
+	})
+
+	t.Run("not consecutive", func(t *testing.T) {
+		g := offload.NewGRO(offload.MaxSize)
+		g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, 100, header.TCPFlagAck, data(1000)))
+		g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, 3000, header.TCPFlagAck, data(1000)))
+		g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, 4000, header.TCPFlagAck|header.TCPFlagSyn, data(1000)))
+
+		items := g.Flush()
+		require.Len(t, items, 3)
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		g := offload.NewGRO(3000)
+		for i := 0; i < 4; i++ {
+			g.Add(build(t, saddr4, daddr4, header.TCPProtocolNumber, uint32(100+1000*i), header.TCPFlagAck, data(1000)))
+		}
+		items := g.Flush()
+		require.Len(t, items, 2)
+		require.Equal(t, 2000+40, items[0].Data())
+		require.Equal(t, 2000+40, items[1].Data())
+	})
+}
@@ -0,0 +1,60 @@
+package offload
+
+import (
+	"github.com/lysShub/netkit/packet"
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// Segment split tcp/udp ip packet into segments, the payload of each segment
+// not exceed mss, fix ip id, length, tcp sequence number and checksums. ip
+// packet start at p.Bytes(), the segments reserve the same head section as p,
+// for attach link header.
+func Segment(p *packet.Packet, mss int) ([]*packet.Packet, error) {
+	if mss <= 0 {
+		return nil, errors.Errorf("invalid mss %d", mss)
+	}
+
+	ip := p.Bytes()
+	info, err := parse(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		payload = ip[info.hdrLen:]
+		segs    = make([]*packet.Packet, 0, max((len(payload)+mss-1)/mss, 1))
+	)
+	if len(payload) <= mss {
+		return append(segs, p), nil
+	}
+	for i, off := 0, 0; off < len(payload); i, off = i+1, off+mss {
+		end := min(off+mss, len(payload))
+
+		seg := packet.Make(p.Head(), info.hdrLen+end-off, 0)
+		b := seg.Bytes()
+		copy(b, ip[:info.hdrLen])
+		copy(b[info.hdrLen:], payload[off:end])
+
+		if info.ipv4 {
+			iphdr := header.IPv4(b)
+			iphdr.SetID(iphdr.ID() + uint16(i))
+		}
+		if info.proto == header.TCPProtocolNumber {
+			tcphdr := header.TCP(b[info.l4:])
+			tcphdr.SetSequenceNumber(tcphdr.SequenceNumber() + uint32(off))
+			flags := tcphdr.Flags()
+			if end != len(payload) {
+				flags &^= header.TCPFlagFin | header.TCPFlagPsh
+			}
+			if i > 0 {
+				flags &^= header.TCPFlagCwr
+			}
+			tcphdr.SetFlags(uint8(flags))
+		}
+		info.fixup(b)
+
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
@@ -2,6 +2,7 @@ package route
 
 import (
 	"fmt"
+	"hash/fnv"
 	"net/netip"
 	"strconv"
 	"strings"
@@ -15,15 +16,132 @@ type Entry struct {
 	Next netip.Addr `json:"next"`
 
 	// src interface index and correspond address, actually one
-	// interface can with multiple addresses, just select one.
+	// interface can with multiple addresses, Addr is the preferred
+	// source address.
 	Interface uint32     `json:"ifi"`
-	Addr      netip.Addr `json:"addr"` // todo: Addrs
+	Addr      netip.Addr `json:"addr"`
+
+	// Addrs all usable addresses of the interface with same family as
+	// Dest, sorted by source address selection(RFC 6724), Addrs[0] is Addr
+	// if Addr belong to the interface.
+	Addrs []netip.Addr `json:"addrs,omitempty"`
 
 	Metric uint32 `json:"metric"`
 
+	// Table route table id, such as TableMain, zero means main table when
+	// Add, only linux
+	Table uint32 `json:"table,omitempty"`
+
+	// Type route type, zero means TypeUnicast when Add, only linux
+	Type Type `json:"type,omitempty"`
+
+	// NextHops next hops of multipath(ECMP) route, Next/Interface/Addr is the
+	// first hop. when Add, Next/Interface is ignored if NextHops not empty,
+	// only linux
+	NextHops []NextHop `json:"nexthops,omitempty"`
+
 	raw EntryRaw
 }
 
+// NextHop one next hop of multipath route
+type NextHop struct {
+	Next      netip.Addr `json:"next"`
+	Interface uint32     `json:"ifi"`
+	Addr      netip.Addr `json:"addr"`
+
+	// Addrs addresses of the interface, same as Entry.Addrs
+	Addrs []netip.Addr `json:"addrs,omitempty"`
+
+	// Weight relative weight of the hop, 1-256, zero means 1
+	Weight uint16 `json:"weight"`
+}
+
+func (h NextHop) weight() uint32 {
+	if h.Weight == 0 {
+		return 1
+	}
+	return uint32(h.Weight)
+}
+
+// Select select next hop by flow hash, return the entry with Next/Interface/Addr/Addrs
+// of selected hop, the same hash always select the same hop, hop be selected
+// with probability proportional to its weight. see FlowHash.
+func (e Entry) Select(hash uint32) Entry {
+	if len(e.NextHops) == 0 {
+		return e
+	}
+
+	var total uint32
+	for _, h := range e.NextHops {
+		total += h.weight()
+	}
+	n := hash % total
+	for _, h := range e.NextHops {
+		if n < h.weight() {
+			e.Next, e.Interface, e.Addr, e.Addrs = h.Next, h.Interface, h.Addr, h.Addrs
+			break
+		}
+		n -= h.weight()
+	}
+	return e
+}
+
+// FlowHash hash of flow 5-tuple, used to Select next hop.
+func FlowHash(src, dst netip.AddrPort, proto uint8) uint32 {
+	var h = fnv.New32a()
+	h.Write(src.Addr().AsSlice())
+	h.Write(dst.Addr().AsSlice())
+	h.Write([]byte{
+		proto,
+		byte(src.Port() >> 8), byte(src.Port()),
+		byte(dst.Port() >> 8), byte(dst.Port()),
+	})
+	return h.Sum32()
+}
+
+const (
+	TableDefault uint32 = 253 // RT_TABLE_DEFAULT
+	TableMain    uint32 = 254 // RT_TABLE_MAIN
+	TableLocal   uint32 = 255 // RT_TABLE_LOCAL
+)
+
+// Type route type, same as linux RTN_*
+type Type uint8
+
+const (
+	TypeUnicast     Type = 1 // gateway or direct route
+	TypeLocal       Type = 2 // local address
+	TypeBroadcast   Type = 3
+	TypeAnycast     Type = 4
+	TypeMulticast   Type = 5
+	TypeBlackhole   Type = 6 // drop silently
+	TypeUnreachable Type = 7 // drop and reply icmp host unreachable
+	TypeProhibit    Type = 8 // drop and reply icmp administratively prohibited
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeUnicast:
+		return "unicast"
+	case TypeLocal:
+		return "local"
+	case TypeBroadcast:
+		return "broadcast"
+	case TypeAnycast:
+		return "anycast"
+	case TypeMulticast:
+		return "multicast"
+	case TypeBlackhole:
+		return "blackhole"
+	case TypeUnreachable:
+		return "unreachable"
+	case TypeProhibit:
+		return "prohibit"
+	default:
+		return fmt.Sprintf("type(%d)", uint8(t))
+	}
+}
+
 func (e Entry) Valid() bool {
 	return e.Dest.IsValid() && e.Interface != 0
 }
@@ -14,3 +14,8 @@ type EntryRaw []syscall.NetlinkRouteAttr
 func (e *Entry) Name() (string, error) {
 	return netcall.IoctlGifname(int(e.Interface))
 }
+
+// Stats get traffic counters of the interface
+func (e *Entry) Stats() (*netcall.LinkStats, error) {
+	return netcall.GetLinkStats(int(e.Interface))
+}
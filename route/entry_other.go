@@ -3,8 +3,16 @@
 
 package route
 
+import (
+	netcall "github.com/lysShub/netkit/syscall"
+)
+
 type EntryRaw struct{}
 
 func (e *Entry) Name() (string, error) {
 	panic("not support")
 }
+
+func (e *Entry) Stats() (*netcall.LinkStats, error) {
+	panic("not support")
+}
@@ -5,6 +5,7 @@ package route
 
 import (
 	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
@@ -13,3 +14,21 @@ type EntryRaw = winipcfg.MibIPforwardRow2
 func (e *Entry) Name() (string, error) {
 	return netcall.ConvertInterfaceLuidToAlias(uint64(e.raw.InterfaceLUID))
 }
+
+// Stats get traffic counters of the interface
+func (e *Entry) Stats() (*netcall.LinkStats, error) {
+	row, err := e.raw.InterfaceLUID.Interface()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &netcall.LinkStats{
+		RxPackets: row.InUcastPkts + row.InNUcastPkts,
+		TxPackets: row.OutUcastPkts + row.OutNUcastPkts,
+		RxBytes:   row.InOctets,
+		TxBytes:   row.OutOctets,
+		RxErrors:  row.InErrors,
+		TxErrors:  row.OutErrors,
+		RxDropped: row.InDiscards,
+		TxDropped: row.OutDiscards,
+	}, nil
+}
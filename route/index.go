@@ -0,0 +1,312 @@
+package route
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+	"slices"
+)
+
+// Index immutable longest-prefix-match index of route entries, it's path-compressed
+// binary trie, Match cost O(prefix length) instead of scan all entries, and
+// return same entry as Table.Match/MatchFunc of sorted table(see Table.Sort).
+// Insert/DeleteFunc return new Index by path copying, only O(prefix length)
+// nodes be copied, the old Index still valid, e.g:
+//
+//	idx := route.NewIndex(table)
+//	e := idx.Match(dst)
+//	idx = idx.Insert(entry)
+type Index struct {
+	v4, v6 *node
+	seq    uint64 // insert sequence, later entry win if same priority
+}
+
+type node struct {
+	prefix netip.Prefix // masked
+	child  [2]*node
+
+	// entries with the prefix, sorted by priority ascending, last is best
+	entries []item
+}
+
+type item struct {
+	e   Entry
+	seq uint64
+}
+
+func (a item) compare(b item) int {
+	if c := -less(a.e, b.e); c != 0 {
+		return c
+	} else if a.seq < b.seq {
+		return -1
+	} else if a.seq > b.seq {
+		return 1
+	}
+	return 0
+}
+
+// NewIndex build index of table, O(n*bits).
+func NewIndex(table Table) *Index {
+	var x = &Index{}
+	for _, e := range table {
+		x.insert(e, true)
+	}
+	return x
+}
+
+// Insert return new Index with e inserted, the e has highest priority in
+// entries with same priority, same as append to table then Table.Sort.
+func (x *Index) Insert(e Entry) *Index {
+	var y = *x
+	y.insert(e, false)
+	return &y
+}
+
+// insert e, modify nodes in place if inplace, otherwise copy the path
+func (x *Index) insert(e Entry, inplace bool) {
+	if !e.Dest.IsValid() {
+		return
+	}
+	x.seq++
+
+	it := item{e: e, seq: x.seq}
+	p := e.Dest.Masked()
+	if p.Addr().Is4() {
+		x.v4 = x.v4.insert(p, it, inplace)
+	} else {
+		x.v6 = x.v6.insert(p, it, inplace)
+	}
+}
+
+func (n *node) insert(p netip.Prefix, it item, inplace bool) *node {
+	if n == nil {
+		return &node{prefix: p, entries: []item{it}}
+	}
+
+	bits := commonBits(n.prefix, p)
+	switch {
+	case bits == n.prefix.Bits() && bits == p.Bits():
+		m := n.copy(inplace)
+		i, _ := slices.BinarySearchFunc(m.entries, it, item.compare)
+		if !inplace {
+			m.entries = slices.Clone(m.entries)
+		}
+		m.entries = slices.Insert(m.entries, i, it)
+		return m
+	case bits == n.prefix.Bits(): // p under n
+		m := n.copy(inplace)
+		b := bit(p.Addr(), bits)
+		m.child[b] = m.child[b].insert(p, it, inplace)
+		return m
+	case bits == p.Bits(): // n under p
+		m := &node{prefix: p, entries: []item{it}}
+		m.child[bit(n.prefix.Addr(), bits)] = n
+		return m
+	default: // split by common prefix
+		m := &node{prefix: netip.PrefixFrom(p.Addr(), bits).Masked()}
+		m.child[bit(n.prefix.Addr(), bits)] = n
+		m.child[bit(p.Addr(), bits)] = &node{prefix: p, entries: []item{it}}
+		return m
+	}
+}
+
+func (n *node) copy(inplace bool) *node {
+	if inplace {
+		return n
+	}
+	m := *n
+	return &m
+}
+
+// DeleteFunc return new Index without entries that Dest equal dest and fn
+// return true, return x self if nothing deleted.
+func (x *Index) DeleteFunc(dest netip.Prefix, fn func(Entry) (del bool)) *Index {
+	if !dest.IsValid() {
+		return x
+	}
+	var y = *x
+	var deleted bool
+	if dest.Addr().Is4() {
+		y.v4, deleted = y.v4.delete(dest, fn)
+	} else {
+		y.v6, deleted = y.v6.delete(dest, fn)
+	}
+	if !deleted {
+		return x
+	}
+	return &y
+}
+
+func (n *node) delete(dest netip.Prefix, fn func(Entry) bool) (*node, bool) {
+	p := dest.Masked()
+	if n == nil || n.prefix.Bits() > p.Bits() || !n.prefix.Contains(p.Addr()) {
+		return n, false
+	}
+
+	m := *n
+	if n.prefix.Bits() < p.Bits() {
+		b := bit(p.Addr(), n.prefix.Bits())
+		var deleted bool
+		if m.child[b], deleted = n.child[b].delete(dest, fn); !deleted {
+			return n, false
+		}
+	} else {
+		m.entries = slices.DeleteFunc(slices.Clone(n.entries), func(it item) bool {
+			return it.e.Dest == dest && fn(it.e)
+		})
+		if len(m.entries) == len(n.entries) {
+			return n, false
+		}
+	}
+
+	// remove the node if it's useless
+	if len(m.entries) == 0 {
+		if m.child[0] == nil {
+			return m.child[1], true
+		} else if m.child[1] == nil {
+			return m.child[0], true
+		}
+	}
+	return &m, true
+}
+
+// update return new Index with entries replaced by fn, fn return false if not
+// changed, the priority of entry must not be changed. return x self if nothing
+// changed.
+func (x *Index) update(fn func(Entry) (Entry, bool)) *Index {
+	var y = *x
+	v4, ok4 := y.v4.update(fn)
+	v6, ok6 := y.v6.update(fn)
+	if !ok4 && !ok6 {
+		return x
+	}
+	y.v4, y.v6 = v4, v6
+	return &y
+}
+
+func (n *node) update(fn func(Entry) (Entry, bool)) (*node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	var m *node
+	for i, it := range n.entries {
+		if e, ok := fn(it.e); ok {
+			if m == nil {
+				m = n.copy(false)
+				m.entries = slices.Clone(n.entries)
+			}
+			m.entries[i].e = e
+		}
+	}
+	for i, c := range n.child {
+		if c, ok := c.update(fn); ok {
+			if m == nil {
+				m = n.copy(false)
+			}
+			m.child[i] = c
+		}
+	}
+	if m == nil {
+		return n, false
+	}
+	return m, true
+}
+
+// commonBits length of common prefix of a and b, a and b must be same family
+func commonBits(a, b netip.Prefix) int {
+	x, y := u128(a.Addr()), u128(b.Addr())
+	n := bits.LeadingZeros64(x[0] ^ y[0])
+	if n == 64 {
+		n += bits.LeadingZeros64(x[1] ^ y[1])
+	}
+	if a.Addr().Is4() {
+		n -= 96 // ipv4-mapped
+	}
+	return min(n, a.Bits(), b.Bits())
+}
+
+// bit the i-th bit of addr, from high to low
+func bit(addr netip.Addr, i int) int {
+	if addr.Is4() {
+		i += 96 // ipv4-mapped
+	}
+	x := u128(addr)
+	return int(x[i/64]>>(63-i%64)) & 1
+}
+
+func u128(addr netip.Addr) [2]uint64 {
+	b := addr.As16()
+	return [2]uint64{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+// Table entries of index, sorted same as Table.Sort
+func (x *Index) Table() Table {
+	var items []item
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n != nil {
+			items = append(items, n.entries...)
+			walk(n.child[0])
+			walk(n.child[1])
+		}
+	}
+	walk(x.v4)
+	walk(x.v6)
+
+	slices.SortFunc(items, item.compare)
+	var table = make(Table, 0, len(items))
+	for _, e := range items {
+		table = append(table, e.e)
+	}
+	return table
+}
+
+// Match match best route entry, same as Table.Match
+func (x *Index) Match(dst netip.Addr) Entry {
+	return x.MatchFunc(dst, nil)
+}
+
+// MatchFunc same as Table.MatchFunc
+func (x *Index) MatchFunc(dst netip.Addr, fn func(Entry) (hit bool)) Entry {
+	var path = make([]*node, 0, 8)
+	x.walk(dst, func(n *node) { path = append(path, n) })
+	for i := len(path) - 1; i >= 0; i-- {
+		for j := len(path[i].entries) - 1; j >= 0; j-- {
+			e := path[i].entries[j].e
+			if e.Addr.IsValid() && (fn == nil || fn(e)) {
+				return e
+			}
+		}
+	}
+	return Entry{}
+}
+
+// MatchFlow same as Table.MatchFlow
+func (x *Index) MatchFlow(dst netip.Addr, hash uint32) Entry {
+	return x.Match(dst).Select(hash)
+}
+
+// walk visit nodes with entries contain dst, from short prefix to long
+func (x *Index) walk(dst netip.Addr, fn func(*node)) {
+	if !dst.IsValid() || dst.Zone() != "" {
+		return // Prefix.Contains never contain zoned address
+	}
+	n, off := x.v6, 0
+	if dst.Is4() {
+		n, off = x.v4, 96
+	}
+
+	d := u128(dst)
+	for n != nil && n.prefix.Contains(dst) {
+		if len(n.entries) > 0 {
+			fn(n)
+		}
+		i := n.prefix.Bits()
+		if i == dst.BitLen() {
+			break
+		}
+		i += off
+		n = n.child[(d[i/64]>>(63-i%64))&1]
+	}
+}
@@ -0,0 +1,192 @@
+//go:build linux
+// +build linux
+
+package route
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/lysShub/netkit/errorx"
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Add add route entry by RTM_NEWROUTE, Entry.Addr as preferred source address,
+// return error satisfy errors.Is(err, os.ErrExist) if the entry exist. e.g:
+//
+//	route.Add(route.Entry{Dest: netip.MustParsePrefix("10.0.0.0/8"), Interface: uint32(tunIfi)})
+func Add(e Entry) error {
+	return modify(unix.RTM_NEWROUTE, unix.NLM_F_CREATE|unix.NLM_F_EXCL, e)
+}
+
+// Replace add route entry, or replace the exist entry with same dest, metric
+// and table.
+func Replace(e Entry) error {
+	return modify(unix.RTM_NEWROUTE, unix.NLM_F_CREATE|unix.NLM_F_REPLACE, e)
+}
+
+// Delete delete route entry by RTM_DELROUTE, unset fields of e match any, except
+// Table, zero Table is main table(kernel not support match any table). return
+// errorx.NotFound error if not exist.
+func Delete(e Entry) error {
+	err := modify(unix.RTM_DELROUTE, 0, e)
+	if errors.Is(err, unix.ESRCH) {
+		return errorx.WrapNotfound(errors.Errorf("not found route %s", e.Dest.String()))
+	}
+	return err
+}
+
+func modify(typ, flags uint16, e Entry) error {
+	msg, attrs, err := routeMsg(typ, e)
+	if err != nil {
+		return err
+	}
+
+	_, err = netcall.NetlinkRequest(typ, flags, netcall.StructBytes(msg), attrs...)
+	if errors.Is(err, unix.EEXIST) {
+		return errors.WithMessagef(err, "route %s exist", e.Dest.String())
+	}
+	return err
+}
+
+func routeMsg(typ uint16, e Entry) (*unix.RtMsg, []netcall.NetlinkAttr, error) {
+	if !e.Dest.IsValid() {
+		return nil, nil, errors.Errorf("invalid dest %s", e.Dest.String())
+	} else if e.Next.IsValid() && e.Next.Is4() != e.Dest.Addr().Is4() {
+		return nil, nil, errors.Errorf("next hop %s family not match", e.Next.String())
+	} else if e.Addr.IsValid() && e.Addr.Is4() != e.Dest.Addr().Is4() {
+		return nil, nil, errors.Errorf("source %s family not match", e.Addr.String())
+	}
+
+	// link-local next hop require interface, get it from zone
+	ifi := e.Interface
+	if ifi == 0 && e.Next.Zone() != "" {
+		i, err := zoneIndex(e.Next.Zone())
+		if err != nil {
+			return nil, nil, err
+		}
+		ifi = uint32(i)
+	}
+	rtype := e.Type
+	if rtype == 0 {
+		rtype = TypeUnicast
+	}
+	if typ == unix.RTM_NEWROUTE && ifi == 0 && !e.Next.IsValid() && rtype == TypeUnicast && len(e.NextHops) == 0 {
+		return nil, nil, errors.New("require interface or next hop")
+	}
+
+	var msg = &unix.RtMsg{
+		Family:  unix.AF_INET,
+		Dst_len: uint8(e.Dest.Bits()),
+		Table:   unix.RT_TABLE_MAIN,
+		Scope:   unix.RT_SCOPE_NOWHERE, // match any when delete
+	}
+	if e.Dest.Addr().Is6() {
+		msg.Family = unix.AF_INET6
+	}
+	if e.Table != 0 {
+		msg.Table = unix.RT_TABLE_UNSPEC // by RTA_TABLE
+		if e.Table < 256 {
+			msg.Table = uint8(e.Table)
+		}
+	}
+	if typ == unix.RTM_NEWROUTE {
+		msg.Protocol = unix.RTPROT_BOOT
+		msg.Type = uint8(rtype)
+		switch {
+		case rtype == TypeLocal:
+			msg.Scope = unix.RT_SCOPE_HOST
+		case rtype == TypeBroadcast, rtype == TypeUnicast && !e.Next.IsValid() && len(e.NextHops) == 0:
+			msg.Scope = unix.RT_SCOPE_LINK
+		default:
+			msg.Scope = unix.RT_SCOPE_UNIVERSE
+		}
+	} else {
+		msg.Type = uint8(e.Type) // zero match any
+	}
+
+	var attrs []netcall.NetlinkAttr
+	if e.Dest.Bits() > 0 {
+		attrs = append(attrs, netcall.BytesAttr(unix.RTA_DST, e.Dest.Masked().Addr().AsSlice()))
+	}
+	if len(e.NextHops) > 0 {
+		if typ == unix.RTM_NEWROUTE {
+			mp, err := multipath(e)
+			if err != nil {
+				return nil, nil, err
+			}
+			attrs = append(attrs, netcall.BytesAttr(unix.RTA_MULTIPATH, mp))
+		}
+	} else {
+		if e.Next.IsValid() {
+			attrs = append(attrs, netcall.BytesAttr(unix.RTA_GATEWAY, e.Next.WithZone("").AsSlice()))
+		}
+		if ifi != 0 {
+			attrs = append(attrs, netcall.Uint32Attr(unix.RTA_OIF, ifi))
+		}
+	}
+	if e.Addr.IsValid() {
+		attrs = append(attrs, netcall.BytesAttr(unix.RTA_PREFSRC, e.Addr.AsSlice()))
+	}
+	if e.Metric != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.RTA_PRIORITY, e.Metric))
+	}
+	if e.Table != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.RTA_TABLE, e.Table))
+	}
+	return msg, attrs, nil
+}
+
+// multipath encode RTA_MULTIPATH value, sequence of rtnexthop with RTA_GATEWAY
+func multipath(e Entry) ([]byte, error) {
+	var b []byte
+	for _, h := range e.NextHops {
+		if h.Next.IsValid() && h.Next.Is4() != e.Dest.Addr().Is4() {
+			return nil, errors.Errorf("next hop %s family not match", h.Next.String())
+		} else if h.Weight > 256 {
+			return nil, errors.Errorf("invalid next hop weight %d", h.Weight)
+		}
+
+		ifi := h.Interface
+		if ifi == 0 && h.Next.Zone() != "" {
+			i, err := zoneIndex(h.Next.Zone())
+			if err != nil {
+				return nil, err
+			}
+			ifi = uint32(i)
+		}
+		if ifi == 0 && !h.Next.IsValid() {
+			return nil, errors.New("next hop require interface or next hop")
+		}
+
+		var rtnh = unix.RtNexthop{
+			Len:     unix.SizeofRtNexthop,
+			Hops:    uint8(h.weight() - 1),
+			Ifindex: int32(ifi),
+		}
+		var gw []byte
+		if h.Next.IsValid() {
+			gw = h.Next.WithZone("").AsSlice()
+			rtnh.Len += uint16(unix.SizeofRtAttr + len(gw)) // 4 or 16 bytes, always aligned
+		}
+		b = append(b, netcall.StructBytes(&rtnh)...)
+		if gw != nil {
+			rta := unix.RtAttr{Len: uint16(unix.SizeofRtAttr + len(gw)), Type: unix.RTA_GATEWAY}
+			b = append(append(b, netcall.StructBytes(&rta)...), gw...)
+		}
+	}
+	return b, nil
+}
+
+func zoneIndex(zone string) (int, error) {
+	if i, err := strconv.Atoi(zone); err == nil {
+		return i, nil
+	}
+	ifi, err := net.InterfaceByName(zone)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return ifi.Index, nil
+}
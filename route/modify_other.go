@@ -0,0 +1,16 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package route
+
+func Add(e Entry) error {
+	panic("not support")
+}
+
+func Replace(e Entry) error {
+	panic("not support")
+}
+
+func Delete(e Entry) error {
+	panic("not support")
+}
@@ -0,0 +1,85 @@
+//go:build windows
+// +build windows
+
+package route
+
+import (
+	"net/netip"
+	"os"
+
+	"github.com/lysShub/netkit/errorx"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// Add add route entry, return error satisfy errors.Is(err, os.ErrExist) if
+// the entry exist, Entry.Addr and Entry.Table are ignored.
+func Add(e Entry) error {
+	luid, next, err := routeRow(e)
+	if err != nil {
+		return err
+	}
+
+	err = luid.AddRoute(e.Dest.Masked(), next, e.Metric)
+	if errors.Is(err, windows.ERROR_OBJECT_ALREADY_EXISTS) {
+		return errors.WithMessagef(os.ErrExist, "route %s exist", e.Dest.String())
+	}
+	return errors.WithStack(err)
+}
+
+// Replace add route entry, or update metric of the exist entry with same dest
+// and next hop.
+func Replace(e Entry) error {
+	luid, next, err := routeRow(e)
+	if err != nil {
+		return err
+	}
+
+	row, err := luid.Route(e.Dest.Masked(), next)
+	if errors.Is(err, windows.ERROR_NOT_FOUND) {
+		return errors.WithStack(luid.AddRoute(e.Dest.Masked(), next, e.Metric))
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+	row.Metric = e.Metric
+	return errors.WithStack(row.Set())
+}
+
+// Delete delete route entry, return errorx.NotFound error if not exist.
+func Delete(e Entry) error {
+	luid, next, err := routeRow(e)
+	if err != nil {
+		return err
+	}
+
+	err = luid.DeleteRoute(e.Dest.Masked(), next)
+	if errors.Is(err, windows.ERROR_NOT_FOUND) {
+		return errorx.WrapNotfound(errors.Errorf("not found route %s", e.Dest.String()))
+	}
+	return errors.WithStack(err)
+}
+
+func routeRow(e Entry) (winipcfg.LUID, netip.Addr, error) {
+	if !e.Dest.IsValid() {
+		return 0, netip.Addr{}, errors.Errorf("invalid dest %s", e.Dest.String())
+	} else if e.Interface == 0 {
+		return 0, netip.Addr{}, errors.New("require interface")
+	}
+	luid, err := winipcfg.LUIDFromIndex(e.Interface)
+	if err != nil {
+		return 0, netip.Addr{}, errors.WithStack(err)
+	}
+
+	next := e.Next.WithZone("")
+	if !next.IsValid() { // on-link
+		if e.Dest.Addr().Is4() {
+			next = netip.IPv4Unspecified()
+		} else {
+			next = netip.IPv6Unspecified()
+		}
+	} else if next.Is4() != e.Dest.Addr().Is4() {
+		return 0, netip.Addr{}, errors.Errorf("next hop %s family not match", e.Next.String())
+	}
+	return luid, next, nil
+}
@@ -0,0 +1,69 @@
+package route
+
+import "net/netip"
+
+type Option func(*Configs)
+
+func Options(opts ...Option) *Configs {
+	cfg := &Configs{uid: -1}
+	for _, e := range opts {
+		e(cfg)
+	}
+	if !cfg.ipv4 && !cfg.ipv6 {
+		cfg.ipv4 = true // compatible, default only ipv4
+	}
+	return cfg
+}
+
+// IPv4 get ipv4 route entries, it's default if not set any family
+func IPv4(c *Configs) { c.ipv4 = true }
+
+// IPv6 get ipv6 route entries, default only get ipv4 entries, set both IPv4
+// and IPv6 to get all families
+func IPv6(c *Configs) { c.ipv6 = true }
+
+// addrEvent Watch also emit eventAddr after addresses of interface changed
+func addrEvent(c *Configs) { c.addrEvent = true }
+
+// TableID only get entries of the route table, such as TableMain, default
+// get entries of all tables, only linux
+func TableID(id uint32) Option {
+	return func(c *Configs) { c.table = id }
+}
+
+// Src source address of the flow, only used by Get
+func Src(addr netip.Addr) Option {
+	return func(c *Configs) { c.src = addr }
+}
+
+// OIf output interface of the flow, only used by Get
+func OIf(ifi uint32) Option {
+	return func(c *Configs) { c.oif = ifi }
+}
+
+// Mark fwmark of the flow, only used by Get, only linux
+func Mark(mark uint32) Option {
+	return func(c *Configs) { c.mark = mark }
+}
+
+// UID owner uid of the flow, only used by Get, only linux
+func UID(uid uint32) Option {
+	return func(c *Configs) { c.uid = int64(uid) }
+}
+
+type Configs struct {
+	ipv4, ipv6 bool
+	table      uint32
+	addrEvent  bool // Watch emit eventAddr, used by Snapshot
+
+	// flow of Get
+	src  netip.Addr
+	oif  uint32
+	mark uint32
+	uid  int64
+}
+
+// match whether entry match the table filter
+func (c *Configs) match(e *Entry) bool {
+	return c.table == 0 || e.Table == c.table
+}
@@ -12,6 +12,14 @@ func (t Table) Match(dst netip.Addr) Entry {
 	return t.matchFunc(dst, nil)
 }
 
+// MatchFlow match best route entry, select next hop by flow hash if it's
+// multipath route, e.g:
+//
+//	t.MatchFlow(dst.Addr(), route.FlowHash(src, dst, unix.IPPROTO_TCP))
+func (t Table) MatchFlow(dst netip.Addr, hash uint32) Entry {
+	return t.matchFunc(dst, nil).Select(hash)
+}
+
 func (t Table) MatchFunc(dst netip.Addr, fn func(Entry) (hit bool)) Entry {
 	return t.matchFunc(dst, fn)
 }
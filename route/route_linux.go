@@ -6,9 +6,12 @@ package route
 import (
 	"net"
 	"net/netip"
+	"slices"
+	"strconv"
 	"syscall"
 	"unsafe"
 
+	"github.com/lysShub/netkit/errorx"
 	netcall "github.com/lysShub/netkit/syscall"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -22,10 +25,27 @@ func (e *Entry) HardwareAddr() (net.HardwareAddr, error) {
 	return netcall.IoctlGifhwaddr(name)
 }
 
-// GetTable get ipv4 route entries
-func GetTable() (Table, error) {
+// GetTable get route entries of all tables, default only ipv4, can select
+// family by IPv4/IPv6 option, table by TableID option, e.g:
+//
+//	GetTable(route.IPv6)
+//	GetTable(route.IPv4, route.IPv6)
+//	GetTable(route.IPv4, route.TableID(route.TableMain))
+func GetTable(opts ...Option) (Table, error) {
+	cfg := Options(opts...)
+	family := unix.AF_UNSPEC
+	if !cfg.ipv6 {
+		family = unix.AF_INET
+	} else if !cfg.ipv4 {
+		family = unix.AF_INET6
+	}
+
 	// todo: set socket timeout
-	tab, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, unix.AF_INET)
+	tab, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := newIfAddrs()
 	if err != nil {
 		return nil, err
 	}
@@ -39,29 +59,12 @@ func GetTable() (Table, error) {
 		m := msgs[i]
 		switch m.Header.Type {
 		case unix.RTM_NEWROUTE:
-			attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+			e, ok, err := parseEntry(&m, addrs)
 			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-			rt := (*unix.RtMsg)(unsafe.Pointer(unsafe.SliceData(m.Data)))
-			e := collectEntry(attrs, rt.Dst_len)
-			if !e.Dest.IsValid() {
-				e.Dest = netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+				return nil, err
+			} else if ok && cfg.match(&e) {
+				table = append(table, e)
 			}
-			if !e.Addr.IsValid() {
-				name, err := netcall.IoctlGifname(int(e.Interface))
-				if err != nil {
-					return nil, err
-				}
-				addr, err := netcall.IoctlGifaddr(name)
-				if err != nil {
-					return nil, err
-				}
-				e.Addr = addr.Addr()
-			}
-			e.raw = attrs
-
-			table = append(table, e)
 		case unix.NLMSG_DONE:
 			i = len(msgs) // break
 		case unix.NLMSG_NOOP:
@@ -81,6 +84,150 @@ func GetTable() (Table, error) {
 	return table, nil
 }
 
+// Get get the route entry kernel used to send packet to dst by RTM_GETROUTE,
+// respect policy rules, can set flow by Src/OIf/Mark/UID option, e.g:
+//
+//	route.Get(netip.MustParseAddr("8.8.8.8"), route.Mark(0x100))
+//
+// the Dest of returned entry is dst self, Table is the table matched, Next
+// and Interface is the selected hop of multipath route. return errorx.NotFound
+// error if dst is unreachable.
+func Get(dst netip.Addr, opts ...Option) (Entry, error) {
+	cfg := Options(opts...)
+	if !dst.IsValid() {
+		return Entry{}, errors.Errorf("invalid dst %s", dst.String())
+	} else if cfg.src.IsValid() && cfg.src.Is4() != dst.Is4() {
+		return Entry{}, errors.Errorf("source %s family not match", cfg.src.String())
+	}
+
+	var msg = &unix.RtMsg{
+		Family:  unix.AF_INET,
+		Dst_len: uint8(dst.BitLen()),
+		Flags:   unix.RTM_F_LOOKUP_TABLE,
+	}
+	if dst.Is6() {
+		msg.Family = unix.AF_INET6
+	}
+	var attrs = []netcall.NetlinkAttr{
+		netcall.BytesAttr(unix.RTA_DST, dst.WithZone("").AsSlice()),
+	}
+	if cfg.src.IsValid() {
+		msg.Src_len = uint8(cfg.src.BitLen())
+		attrs = append(attrs, netcall.BytesAttr(unix.RTA_SRC, cfg.src.AsSlice()))
+	}
+	oif := cfg.oif
+	if oif == 0 && dst.Zone() != "" {
+		i, err := zoneIndex(dst.Zone())
+		if err != nil {
+			return Entry{}, err
+		}
+		oif = uint32(i)
+	}
+	if oif != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.RTA_OIF, oif))
+	}
+	if cfg.mark != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.RTA_MARK, cfg.mark))
+	}
+	if cfg.uid >= 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.RTA_UID, uint32(cfg.uid)))
+	}
+
+	msgs, err := netcall.NetlinkRequest(unix.RTM_GETROUTE, 0, netcall.StructBytes(msg), attrs...)
+	if err != nil {
+		if errors.Is(err, unix.ENETUNREACH) || errors.Is(err, unix.EHOSTUNREACH) {
+			return Entry{}, errorx.WrapNotfound(errors.WithMessagef(err, "not found route to %s", dst.String()))
+		}
+		return Entry{}, err
+	}
+	addrs, err := newIfAddrs()
+	if err != nil {
+		return Entry{}, err
+	}
+	for i := range msgs {
+		if msgs[i].Header.Type != unix.RTM_NEWROUTE {
+			continue
+		}
+		e, ok, err := parseEntry(&msgs[i], addrs)
+		if err != nil {
+			return Entry{}, err
+		} else if ok {
+			return e, nil
+		}
+	}
+	return Entry{}, errors.Errorf("not found route to %s", dst.String())
+}
+
+// parseEntry parse RTM_NEWROUTE/RTM_DELROUTE message, ok is false if isn't
+// ipv4/ipv6 route
+func parseEntry(m *syscall.NetlinkMessage, addrs *ifAddrs) (e Entry, ok bool, err error) {
+	if len(m.Data) < unix.SizeofRtMsg {
+		return Entry{}, false, errors.Errorf("invalid rtmsg %x", m.Data)
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return Entry{}, false, errors.WithStack(err)
+	}
+	rt := (*unix.RtMsg)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+	if rt.Family != unix.AF_INET && rt.Family != unix.AF_INET6 {
+		return Entry{}, false, nil
+	}
+
+	e = collectEntry(attrs, rt.Dst_len)
+	e.Type = Type(rt.Type)
+	if e.Table == 0 {
+		e.Table = uint32(rt.Table)
+	}
+	if !e.Dest.IsValid() {
+		if rt.Family == unix.AF_INET {
+			e.Dest = netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+		} else {
+			e.Dest = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+		}
+	}
+	for i, h := range e.NextHops {
+		if h.Next.Is6() && h.Next.IsLinkLocalUnicast() && h.Interface != 0 {
+			h.Next = h.Next.WithZone(addrs.zone(h.Interface))
+		}
+		e.NextHops[i] = h
+	}
+	if len(e.NextHops) > 0 && e.Interface == 0 && !e.Next.IsValid() {
+		e.Next, e.Interface = e.NextHops[0].Next, e.NextHops[0].Interface
+	}
+	if e.Next.Is6() && e.Next.IsLinkLocalUnicast() && e.Interface != 0 && e.Next.Zone() == "" {
+		e.Next = e.Next.WithZone(addrs.zone(e.Interface))
+	}
+	e.raw = attrs
+	setSource(&e, addrs)
+	return e, true, nil
+}
+
+// setSource select source address of entry and it's next hops, prefer the
+// RTA_PREFSRC of raw attributes.
+func setSource(e *Entry, addrs *ifAddrs) {
+	var prefsrc netip.Addr
+	for _, attr := range e.raw {
+		if attr.Attr.Type == unix.RTA_PREFSRC {
+			prefsrc, _ = netip.AddrFromSlice(attr.Value)
+		}
+	}
+
+	if len(e.NextHops) > 0 {
+		e.NextHops = slices.Clone(e.NextHops)
+	}
+	for i, h := range e.NextHops {
+		if h.Interface != 0 {
+			e.NextHops[i].Addr, e.NextHops[i].Addrs = addrs.preferred(h.Interface, e.Dest.Addr(), prefsrc)
+		}
+	}
+	if e.Interface != 0 {
+		if !prefsrc.IsValid() && e.Type == TypeLocal {
+			prefsrc = e.Dest.Addr() // local address self
+		}
+		e.Addr, e.Addrs = addrs.preferred(e.Interface, e.Dest.Addr(), prefsrc)
+	}
+}
+
 func collectEntry(attrs []syscall.NetlinkRouteAttr, ones uint8) Entry {
 	var e Entry
 	for _, attr := range attrs {
@@ -92,15 +239,112 @@ func collectEntry(attrs []syscall.NetlinkRouteAttr, ones uint8) Entry {
 			if ok {
 				e.Dest = netip.PrefixFrom(addr, int(ones))
 			}
-		case unix.RTA_SRC, unix.RTA_PREFSRC:
+		case unix.RTA_PREFSRC: // RTA_SRC is source prefix of policy route
 			e.Addr, _ = netip.AddrFromSlice(attr.Value)
 		case unix.RTA_OIF:
 			idx := *(*int32)(unsafe.Pointer(unsafe.SliceData(attr.Value)))
 			e.Interface = uint32(idx)
+		case unix.RTA_TABLE:
+			e.Table = *(*uint32)(unsafe.Pointer(unsafe.SliceData(attr.Value)))
 		case unix.RTA_PRIORITY: // unix.RTA_METRICS
 			metric := *(*int32)(unsafe.Pointer(unsafe.SliceData(attr.Value)))
 			e.Metric = uint32(metric)
+		case unix.RTA_MULTIPATH:
+			e.NextHops = parseMultipath(attr.Value)
 		}
 	}
 	return e
 }
+
+// parseMultipath parse RTA_MULTIPATH value, it's sequence of rtnexthop, every
+// rtnexthop followed by it's attributes, such as RTA_GATEWAY.
+func parseMultipath(b []byte) (hops []NextHop) {
+	for len(b) >= unix.SizeofRtNexthop {
+		rtnh := (*unix.RtNexthop)(unsafe.Pointer(unsafe.SliceData(b)))
+		n := int(rtnh.Len)
+		if n < unix.SizeofRtNexthop || n > len(b) {
+			break
+		}
+
+		var h = NextHop{Interface: uint32(rtnh.Ifindex), Weight: uint16(rtnh.Hops) + 1}
+		attrs, _ := netcall.ParseNetlinkAttr(b[unix.SizeofRtNexthop:n])
+		for _, attr := range attrs {
+			if attr.Attr.Type == unix.RTA_GATEWAY {
+				h.Next, _ = netip.AddrFromSlice(attr.Value)
+			}
+		}
+		hops = append(hops, h)
+		b = b[min(rtaAlign(n), len(b)):]
+	}
+	return hops
+}
+
+func rtaAlign(n int) int { return (n + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1) }
+
+// ifAddrs addresses of all interfaces got by once RTM_GETADDR, used to select
+// source address of route entry
+type ifAddrs struct {
+	addrs map[uint32][]netcall.IfAddr
+	names map[uint32]string
+}
+
+func newIfAddrs() (*ifAddrs, error) {
+	addrs, err := netcall.GetIfAddrs(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var a = &ifAddrs{addrs: map[uint32][]netcall.IfAddr{}, names: map[uint32]string{}}
+	for _, e := range addrs {
+		a.addrs[uint32(e.Index)] = append(a.addrs[uint32(e.Index)], e)
+	}
+	return a, nil
+}
+
+// sources addresses of interface, sorted by preference as source address of
+// dst, skip unusable address. see sortSource.
+func (a *ifAddrs) sources(ifi uint32, dst netip.Addr) []netip.Addr {
+	var cands = make([]srcAddr, 0, len(a.addrs[ifi]))
+	for _, e := range a.addrs[ifi] {
+		if e.Flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) != 0 {
+			continue
+		}
+		// IFA_F_SECONDARY is same value as IFA_F_TEMPORARY
+		v4 := e.Prefix.Addr().Is4()
+		cands = append(cands, srcAddr{
+			prefix:     e.Prefix,
+			deprecated: e.Flags&unix.IFA_F_DEPRECATED != 0,
+			secondary:  v4 && e.Flags&unix.IFA_F_SECONDARY != 0,
+			temporary:  !v4 && e.Flags&unix.IFA_F_TEMPORARY != 0,
+		})
+	}
+	return sortSource(cands, dst)
+}
+
+// preferred preferred source address of dst and sorted addresses of interface,
+// prefsrc is RTA_PREFSRC, it's first if valid.
+func (a *ifAddrs) preferred(ifi uint32, dst, prefsrc netip.Addr) (netip.Addr, []netip.Addr) {
+	addrs := a.sources(ifi, dst)
+	if prefsrc.IsValid() {
+		if i := slices.Index(addrs, prefsrc); i > 0 {
+			addrs = slices.Insert(slices.Delete(addrs, i, i+1), 0, prefsrc)
+		}
+		return prefsrc, addrs
+	} else if len(addrs) > 0 {
+		return addrs[0], addrs
+	}
+	return netip.Addr{}, addrs
+}
+
+// zone get interface name as zone of link-local address
+func (a *ifAddrs) zone(ifi uint32) string {
+	if name, has := a.names[ifi]; has {
+		return name
+	}
+	name, err := netcall.IoctlGifname(int(ifi))
+	if err != nil {
+		name = strconv.Itoa(int(ifi))
+	}
+	a.names[ifi] = name
+	return name
+}
@@ -1 +1,618 @@
 package route_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lysShub/netkit/errorx"
+	"github.com/lysShub/netkit/route"
+	"github.com/lysShub/netkit/tun"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_Entry_Stats(t *testing.T) {
+	table, err := route.GetTable()
+	require.NoError(t, err)
+	require.NotEmpty(t, table)
+
+	for _, e := range table {
+		_, err := e.Stats()
+		require.NoError(t, err)
+	}
+
+	ap, err := tun.Tun("testroutestats")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.37.1/24")))
+
+	conn, err := net.Dial("udp", "10.0.37.2:8080")
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	var b = make([]byte, 1536)
+	for i := 0; i < 8; i++ {
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		_, err = ap.Read(ctx, b) // tx counted when read from device
+		require.NoError(t, err)
+	}
+
+	table, err = route.GetTable()
+	require.NoError(t, err)
+	e := table.Match(netip.MustParseAddr("10.0.37.2"))
+	name, err := e.Name()
+	require.NoError(t, err)
+	require.Equal(t, "testroutestats", name)
+	stats, err := e.Stats()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, stats.TxPackets, uint64(8))
+}
+
+func Test_IPv6(t *testing.T) {
+	ap, err := tun.Tun("testroute6")
+	require.NoError(t, err)
+	defer ap.Close()
+	var (
+		v4 = netip.MustParsePrefix("10.0.17.1/24")
+		v6 = netip.MustParsePrefix("fd00:17::1/64")
+	)
+	require.NoError(t, ap.AddAddr(v4))
+	require.NoError(t, ap.AddAddr(v6, tun.NoDAD))
+	ifi, err := net.InterfaceByName("testroute6")
+	require.NoError(t, err)
+	// ipv6 local route be added by dad work asynchronously, even NoDAD
+	require.Eventually(t, func() bool {
+		table, err := route.GetTable(route.IPv6)
+		return err == nil && table.Loopback(v6.Addr())
+	}, time.Second, time.Millisecond*10)
+
+	t.Run("match", func(t *testing.T) {
+		table, err := route.GetTable(route.IPv4, route.IPv6)
+		require.NoError(t, err)
+
+		e := table.Match(netip.MustParseAddr("fd00:17::2"))
+		require.Equal(t, uint32(ifi.Index), e.Interface)
+		require.Equal(t, v6.Addr(), e.Addr)
+		require.Equal(t, v6.Masked(), e.Dest)
+
+		e = table.Match(netip.MustParseAddr("10.0.17.2"))
+		require.Equal(t, uint32(ifi.Index), e.Interface)
+		require.Equal(t, v4.Addr(), e.Addr)
+
+		require.True(t, table.Loopback(v6.Addr()))
+		require.True(t, table.Loopback(v4.Addr()))
+		require.True(t, table.Loopback(netip.IPv6Loopback()))
+		require.False(t, table.Loopback(netip.MustParseAddr("fd00:17::2")))
+	})
+
+	t.Run("family", func(t *testing.T) {
+		table, err := route.GetTable(route.IPv6)
+		require.NoError(t, err)
+		for _, e := range table {
+			require.True(t, e.Dest.Addr().Is6(), e.Dest.String())
+		}
+
+		for _, opts := range [][]route.Option{{route.IPv4}, nil} { // default only ipv4
+			table, err = route.GetTable(opts...)
+			require.NoError(t, err)
+			for _, e := range table {
+				require.True(t, e.Dest.Addr().Is4(), e.Dest.String())
+			}
+			require.False(t, table.Match(netip.MustParseAddr("fd00:17::2")).Valid())
+		}
+	})
+}
+
+func Test_Modify(t *testing.T) {
+	ap, err := tun.Tun("testroutemod")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.18.1/24")))
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("fd00:18::1/64"), tun.NoDAD))
+	ifi, err := net.InterfaceByName("testroutemod")
+	require.NoError(t, err)
+
+	find := func(e route.Entry) (route.Entry, bool) {
+		table, err := route.GetTable()
+		require.NoError(t, err)
+		for _, r := range table {
+			if r.Dest == e.Dest && r.Interface == e.Interface && (e.Table == 0 || r.Table == e.Table) {
+				return r, true
+			}
+		}
+		return route.Entry{}, false
+	}
+
+	t.Run("add", func(t *testing.T) {
+		e := route.Entry{Dest: netip.MustParsePrefix("10.0.19.0/24"), Interface: uint32(ifi.Index)}
+		require.NoError(t, route.Add(e))
+		defer route.Delete(e)
+
+		r, ok := find(e)
+		require.True(t, ok)
+		require.Equal(t, uint32(unix.RT_TABLE_MAIN), r.Table)
+		require.Equal(t, netip.MustParseAddr("10.0.18.1"), r.Addr)
+
+		err := route.Add(e)
+		require.True(t, errors.Is(err, os.ErrExist), err)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		e := route.Entry{
+			Dest:      netip.MustParsePrefix("10.0.20.0/24"),
+			Interface: uint32(ifi.Index),
+			Metric:    100,
+		}
+		require.NoError(t, route.Replace(e))
+		defer route.Delete(e)
+
+		e.Next = netip.MustParseAddr("10.0.18.2")
+		require.NoError(t, route.Replace(e))
+		r, ok := find(e)
+		require.True(t, ok)
+		require.Equal(t, e.Next, r.Next)
+		require.Equal(t, uint32(100), r.Metric)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		e := route.Entry{Dest: netip.MustParsePrefix("10.0.21.0/24"), Interface: uint32(ifi.Index)}
+		require.NoError(t, route.Add(e))
+
+		require.NoError(t, route.Delete(e))
+		_, ok := find(e)
+		require.False(t, ok)
+
+		err := route.Delete(e)
+		require.True(t, errorx.NotFound(err), err)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		e := route.Entry{
+			Dest: netip.MustParsePrefix("fd00:19::/64"),
+			Next: netip.MustParseAddr("fe80::1%testroutemod"),
+			Addr: netip.MustParseAddr("fd00:18::1"),
+		}
+		require.NoError(t, route.Add(e))
+		defer route.Delete(e)
+
+		table, err := route.GetTable(route.IPv6)
+		require.NoError(t, err)
+		r := table.Match(netip.MustParseAddr("fd00:19::5"))
+		require.Equal(t, uint32(ifi.Index), r.Interface)
+		require.Equal(t, e.Next, r.Next)
+		require.Equal(t, e.Addr, r.Addr)
+	})
+
+	t.Run("table", func(t *testing.T) {
+		e := route.Entry{
+			Dest:      netip.MustParsePrefix("10.0.22.0/24"),
+			Interface: uint32(ifi.Index),
+			Table:     1000,
+		}
+		require.NoError(t, route.Add(e))
+		_, ok := find(e)
+		require.True(t, ok)
+
+		require.NoError(t, route.Delete(e))
+		_, ok = find(e)
+		require.False(t, ok)
+	})
+}
+
+func Test_Multipath(t *testing.T) {
+	var ifis []uint32
+	for i, name := range []string{"testroutemp1", "testroutemp2"} {
+		ap, err := tun.Tun(name)
+		require.NoError(t, err)
+		defer ap.Close()
+		require.NoError(t, ap.AddAddr(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, 27 + byte(i), 1}), 24)))
+		ifi, err := net.InterfaceByName(name)
+		require.NoError(t, err)
+		ifis = append(ifis, uint32(ifi.Index))
+	}
+
+	e := route.Entry{
+		Dest: netip.MustParsePrefix("10.0.29.0/24"),
+		NextHops: []route.NextHop{
+			{Next: netip.MustParseAddr("10.0.27.2"), Interface: ifis[0], Weight: 1},
+			{Next: netip.MustParseAddr("10.0.28.2"), Interface: ifis[1], Weight: 2},
+		},
+	}
+	require.NoError(t, route.Add(e))
+	defer route.Delete(e)
+
+	table, err := route.GetTable(route.IPv4)
+	require.NoError(t, err)
+	r := table.Match(netip.MustParseAddr("10.0.29.1"))
+	require.Equal(t, e.Dest, r.Dest)
+	require.Len(t, r.NextHops, 2)
+	for i, h := range r.NextHops {
+		require.Equal(t, e.NextHops[i].Next, h.Next)
+		require.Equal(t, e.NextHops[i].Interface, h.Interface)
+		require.Equal(t, e.NextHops[i].Weight, h.Weight)
+		require.Equal(t, netip.AddrFrom4([4]byte{10, 0, 27 + byte(i), 1}), h.Addr)
+	}
+	require.Equal(t, r.NextHops[0].Interface, r.Interface)
+	require.Equal(t, r.NextHops[0].Next, r.Next)
+
+	var hit = map[uint32]bool{}
+	for i := uint32(0); i < 16; i++ {
+		hit[table.MatchFlow(netip.MustParseAddr("10.0.29.1"), i).Interface] = true
+	}
+	require.Equal(t, map[uint32]bool{ifis[0]: true, ifis[1]: true}, hit)
+
+	require.NoError(t, route.Delete(e))
+	table, err = route.GetTable(route.IPv4)
+	require.NoError(t, err)
+	require.NotEqual(t, e.Dest, table.Match(netip.MustParseAddr("10.0.29.1")).Dest)
+}
+
+func Test_Watch(t *testing.T) {
+	ap, err := tun.Tun("testroutewatch")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.23.1/24")))
+	ifi, err := net.InterfaceByName("testroutewatch")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := route.Watch(ctx, route.IPv4)
+	require.NoError(t, err)
+	s, err := route.NewSnapshot(ctx)
+	require.NoError(t, err)
+
+	var dst = netip.MustParsePrefix("10.0.24.0/24")
+	next := func(t *testing.T) route.Event {
+		for {
+			select {
+			case e := <-events:
+				if e.Entry.Dest == dst {
+					return e
+				}
+			case <-time.After(time.Second * 3):
+				t.Fatal("wait event timeout")
+			}
+		}
+	}
+
+	e := route.Entry{Dest: dst, Interface: uint32(ifi.Index), Metric: 10}
+	require.NoError(t, route.Add(e))
+	ev := next(t)
+	require.Equal(t, route.EventAdd, ev.Type)
+	require.Equal(t, uint32(ifi.Index), ev.Entry.Interface)
+	require.Equal(t, netip.MustParseAddr("10.0.23.1"), ev.Entry.Addr)
+	require.Eventually(t, func() bool {
+		return s.Match(netip.MustParseAddr("10.0.24.5")).Dest == dst
+	}, time.Second*3, time.Millisecond*10)
+
+	e.Next = netip.MustParseAddr("10.0.23.2")
+	require.NoError(t, route.Replace(e))
+	ev = next(t)
+	require.Equal(t, route.EventReplace, ev.Type)
+	require.Equal(t, e.Next, ev.Entry.Next)
+	require.Eventually(t, func() bool {
+		return s.Match(netip.MustParseAddr("10.0.24.5")).Next == e.Next
+	}, time.Second*3, time.Millisecond*10)
+	n := 0
+	for _, r := range s.Table() {
+		if r.Dest == dst {
+			n++
+		}
+	}
+	require.Equal(t, 1, n)
+
+	require.NoError(t, route.Delete(e))
+	ev = next(t)
+	require.Equal(t, route.EventDelete, ev.Type)
+	require.Eventually(t, func() bool {
+		return s.Match(netip.MustParseAddr("10.0.24.5")).Dest != dst
+	}, time.Second*3, time.Millisecond*10)
+
+	// cached interface addresses be updated after address changed
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.39.1/24")))
+	dst = netip.MustParsePrefix("10.0.39.128/25")
+	e = route.Entry{Dest: dst, Interface: uint32(ifi.Index), Metric: 10}
+	require.NoError(t, route.Add(e))
+	defer route.Delete(e)
+	ev = next(t)
+	require.Equal(t, route.EventAdd, ev.Type)
+	require.Equal(t, netip.MustParseAddr("10.0.39.1"), ev.Entry.Addr)
+	require.Eventually(t, func() bool {
+		return s.Match(netip.MustParseAddr("10.0.39.130")).Addr == netip.MustParseAddr("10.0.39.1")
+	}, time.Second*3, time.Millisecond*10)
+
+	// source address of snapshot entries be updated after address changed
+	require.NoError(t, ap.DelAddr(netip.MustParsePrefix("10.0.39.1/24")))
+	require.Eventually(t, func() bool {
+		return s.Match(netip.MustParseAddr("10.0.39.130")).Addr == netip.MustParseAddr("10.0.23.1")
+	}, time.Second*3, time.Millisecond*10)
+
+	cancel()
+	select {
+	case <-s.Done():
+		require.True(t, errors.Is(s.Err(), context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("snapshot not stop")
+	}
+	for range events {
+	}
+}
+
+func Test_Rule(t *testing.T) {
+	ap, err := tun.Tun("testrouterule")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.25.1/24")))
+	ifi, err := net.InterfaceByName("testrouterule")
+	require.NoError(t, err)
+
+	const table = 1001
+	find := func(r route.Rule) (route.Rule, bool) {
+		rules, err := route.GetRules(route.TableID(table))
+		require.NoError(t, err)
+		for _, e := range rules {
+			if e.Priority == r.Priority {
+				return e, true
+			}
+		}
+		return route.Rule{}, false
+	}
+
+	t.Run("type", func(t *testing.T) {
+		def := route.Entry{Dest: netip.MustParsePrefix("0.0.0.0/0"), Interface: uint32(ifi.Index), Table: table}
+		require.NoError(t, route.Add(def))
+		defer route.Delete(def)
+		black := route.Entry{Dest: netip.MustParsePrefix("10.0.26.0/24"), Type: route.TypeBlackhole, Table: table}
+		require.NoError(t, route.Add(black))
+		defer route.Delete(black)
+
+		entries, err := route.GetTable(route.TableID(table))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		e := entries.Match(netip.MustParseAddr("8.8.8.8"))
+		require.Equal(t, uint32(ifi.Index), e.Interface)
+		require.Equal(t, route.TypeUnicast, e.Type)
+		require.Equal(t, uint32(table), e.Table)
+		for _, e := range entries {
+			if e.Dest == black.Dest {
+				require.Equal(t, route.TypeBlackhole, e.Type)
+			}
+		}
+
+		local, err := route.GetTable(route.TableID(route.TableLocal))
+		require.NoError(t, err)
+		for _, e := range local {
+			require.Equal(t, route.TableLocal, e.Table)
+			if e.Dest == netip.MustParsePrefix("10.0.25.1/32") {
+				require.Equal(t, route.TypeLocal, e.Type)
+			}
+		}
+	})
+
+	t.Run("fwmark", func(t *testing.T) {
+		r := route.Rule{Priority: 1001, Mark: 0x100, Table: table}
+		require.NoError(t, route.AddRule(r))
+		defer route.DelRule(r)
+		err := route.AddRule(r)
+		require.True(t, errors.Is(err, os.ErrExist), err)
+
+		got, ok := find(r)
+		require.True(t, ok)
+		require.Equal(t, uint8(unix.AF_INET), got.Family)
+		require.Equal(t, uint32(0x100), got.Mark)
+		require.Equal(t, uint32(0xffffffff), got.Mask)
+		require.Equal(t, uint8(unix.FR_ACT_TO_TBL), got.Action)
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		r := route.Rule{
+			Priority: 1002,
+			From:     netip.MustParsePrefix("fd00:25::/64"),
+			To:       netip.MustParsePrefix("fd00:26::/64"),
+			IIf:      "testrouterule",
+			Table:    table,
+			Invert:   true,
+		}
+		require.NoError(t, route.AddRule(r))
+		defer route.DelRule(r)
+
+		rules, err := route.GetRules(route.IPv6, route.TableID(table))
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		got := rules[0]
+		require.Equal(t, uint8(unix.AF_INET6), got.Family)
+		require.Equal(t, r.From, got.From)
+		require.Equal(t, r.To, got.To)
+		require.Equal(t, r.IIf, got.IIf)
+		require.True(t, got.Invert)
+
+		rules, err = route.GetRules(route.IPv4, route.TableID(table))
+		require.NoError(t, err)
+		require.Empty(t, rules)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		r := route.Rule{Priority: 1003, OIf: "testrouterule", Table: table}
+		require.NoError(t, route.AddRule(r))
+		require.NoError(t, route.DelRule(r))
+		_, ok := find(r)
+		require.False(t, ok)
+
+		err := route.DelRule(r)
+		require.True(t, errorx.NotFound(err), err)
+	})
+}
+
+func Test_Get(t *testing.T) {
+	ap, err := tun.Tun("testrouteget")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.AddAddr(netip.MustParsePrefix("10.0.30.1/24")))
+	ifi, err := net.InterfaceByName("testrouteget")
+	require.NoError(t, err)
+
+	const table = 1002
+	e := route.Entry{
+		Dest:  netip.MustParsePrefix("10.0.31.0/24"),
+		Next:  netip.MustParseAddr("10.0.30.2"),
+		Table: table,
+	}
+	require.NoError(t, route.Add(e))
+	defer route.Delete(e)
+	err = route.Delete(route.Entry{Dest: e.Dest}) // zero table is main
+	require.True(t, errorx.NotFound(err), err)
+	r := route.Rule{Priority: 1004, Mark: 0x200, Table: table}
+	require.NoError(t, route.AddRule(r))
+	defer route.DelRule(r)
+
+	// main table route of fwmark test, not depend on host default route
+	ap2, err := tun.Tun("testroutegetm")
+	require.NoError(t, err)
+	defer ap2.Close()
+	require.NoError(t, ap2.AddAddr(netip.MustParsePrefix("10.0.38.1/24")))
+	ifi2, err := net.InterfaceByName("testroutegetm")
+	require.NoError(t, err)
+	m := route.Entry{Dest: netip.MustParsePrefix("10.0.30.0/23"), Interface: uint32(ifi2.Index)}
+	require.NoError(t, route.Add(m))
+	defer route.Delete(m)
+
+	t.Run("direct", func(t *testing.T) {
+		got, err := route.Get(netip.MustParseAddr("10.0.30.5"))
+		require.NoError(t, err)
+		require.Equal(t, netip.MustParsePrefix("10.0.30.5/32"), got.Dest)
+		require.Equal(t, uint32(ifi.Index), got.Interface)
+		require.Equal(t, netip.MustParseAddr("10.0.30.1"), got.Addr)
+		require.False(t, got.Next.IsValid())
+		require.Equal(t, route.TableMain, got.Table)
+	})
+
+	t.Run("fwmark", func(t *testing.T) {
+		dst := netip.MustParseAddr("10.0.31.5")
+		got, err := route.Get(dst)
+		require.NoError(t, err)
+		require.Equal(t, uint32(ifi2.Index), got.Interface)
+		require.Equal(t, route.TableMain, got.Table)
+
+		got, err = route.Get(dst, route.Mark(0x200))
+		require.NoError(t, err)
+		require.Equal(t, uint32(ifi.Index), got.Interface)
+		require.Equal(t, e.Next, got.Next)
+		require.Equal(t, uint32(table), got.Table)
+
+		// Match ignore policy rules
+		tab, err := route.GetTable(route.IPv4)
+		require.NoError(t, err)
+		require.Equal(t, uint32(ifi.Index), tab.Match(dst).Interface)
+	})
+
+	t.Run("src", func(t *testing.T) {
+		got, err := route.Get(netip.MustParseAddr("10.0.30.5"), route.Src(netip.MustParseAddr("10.0.30.1")))
+		require.NoError(t, err)
+		require.Equal(t, uint32(ifi.Index), got.Interface)
+		require.Equal(t, netip.MustParseAddr("10.0.30.1"), got.Addr)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		u := route.Entry{Dest: netip.MustParsePrefix("10.0.32.0/24"), Type: route.TypeUnreachable}
+		require.NoError(t, route.Add(u))
+		defer route.Delete(u)
+
+		_, err := route.Get(netip.MustParseAddr("10.0.32.5"))
+		require.True(t, errorx.NotFound(err), err)
+	})
+}
+
+func Test_Addrs(t *testing.T) {
+	ap, err := tun.Tun("testrouteaddrs")
+	require.NoError(t, err)
+	defer ap.Close()
+	for _, a := range []string{"10.0.33.1/24", "10.0.33.2/24"} {
+		require.NoError(t, ap.AddAddr(netip.MustParsePrefix(a)))
+	}
+	for _, a := range []string{"fe80::33/64", "2002:a00:1::1/64", "fd00:33::1/64"} {
+		require.NoError(t, ap.AddAddr(netip.MustParsePrefix(a), tun.NoDAD))
+	}
+	ifi, err := net.InterfaceByName("testrouteaddrs")
+	require.NoError(t, err)
+
+	match := func(dst string) route.Entry {
+		table, err := route.GetTable()
+		require.NoError(t, err)
+		return table.Match(netip.MustParseAddr(dst))
+	}
+
+	t.Run("ipv4", func(t *testing.T) {
+		e := route.Entry{Dest: netip.MustParsePrefix("10.0.34.0/24"), Interface: uint32(ifi.Index)}
+		require.NoError(t, route.Add(e))
+		defer route.Delete(e)
+
+		r := match("10.0.34.1")
+		require.Equal(t, []netip.Addr{
+			netip.MustParseAddr("10.0.33.1"), netip.MustParseAddr("10.0.33.2"), // secondary
+		}, r.Addrs)
+		require.Equal(t, r.Addrs[0], r.Addr)
+	})
+
+	t.Run("prefsrc", func(t *testing.T) {
+		e := route.Entry{
+			Dest:      netip.MustParsePrefix("10.0.35.0/24"),
+			Interface: uint32(ifi.Index),
+			Addr:      netip.MustParseAddr("10.0.33.2"),
+		}
+		require.NoError(t, route.Add(e))
+		defer route.Delete(e)
+
+		r := match("10.0.35.1")
+		require.Equal(t, e.Addr, r.Addr)
+		require.Equal(t, []netip.Addr{
+			netip.MustParseAddr("10.0.33.2"), netip.MustParseAddr("10.0.33.1"),
+		}, r.Addrs)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		find := func(e route.Entry) route.Entry {
+			table, err := route.GetTable(route.IPv6)
+			require.NoError(t, err)
+			for _, r := range table {
+				if r.Dest == e.Dest && r.Interface == e.Interface {
+					return r
+				}
+			}
+			t.Fatal("not found", e.Dest)
+			return route.Entry{}
+		}
+
+		for _, c := range []struct {
+			dest string
+			addr string
+		}{
+			{"fd00:34::/64", "fd00:33::1"},       // matching label
+			{"2002:a00:2::/64", "2002:a00:1::1"}, // matching label
+		} {
+			e := route.Entry{Dest: netip.MustParsePrefix(c.dest), Interface: uint32(ifi.Index)}
+			require.NoError(t, route.Add(e))
+			defer route.Delete(e)
+
+			r := find(e)
+			require.Equal(t, netip.MustParseAddr(c.addr), r.Addr, c.dest)
+			require.Equal(t, r.Addr, r.Addrs[0])
+			require.Contains(t, r.Addrs, netip.MustParseAddr("fe80::33"))
+			require.True(t, r.Addrs[len(r.Addrs)-1].IsLinkLocalUnicast()) // appropriate scope
+		}
+
+		e := route.Entry{Dest: netip.MustParsePrefix("fe80:0:0:1::/64"), Interface: uint32(ifi.Index)}
+		require.NoError(t, route.Add(e))
+		defer route.Delete(e)
+		r := find(e)
+		require.True(t, r.Addr.IsLinkLocalUnicast(), r.Addr)
+		require.True(t, r.Addrs[len(r.Addrs)-1].Is6() && !r.Addrs[len(r.Addrs)-1].IsLinkLocalUnicast())
+	})
+}
@@ -3,6 +3,12 @@
 
 package route
 
-func GetTable() (Table, error) {
+import "net/netip"
+
+func Get(dst netip.Addr, opts ...Option) (Entry, error) {
+	panic("not support")
+}
+
+func GetTable(opts ...Option) (Table, error) {
 	panic("not support")
 }
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"net/netip"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -136,6 +138,117 @@ func Test_Match3(t *testing.T) {
 	require.Equal(t, "0.0.0.0/0", tb[2].Dest.String())
 }
 
+func Test_MatchFlow(t *testing.T) {
+	var table = route.Table{{
+		Dest:      netip.MustParsePrefix("10.0.0.0/8"),
+		Next:      netip.MustParseAddr("192.168.1.1"),
+		Interface: 3,
+		Addr:      netip.MustParseAddr("192.168.1.2"),
+		NextHops: []route.NextHop{
+			{Next: netip.MustParseAddr("192.168.1.1"), Interface: 3, Addr: netip.MustParseAddr("192.168.1.2"), Weight: 1},
+			{Next: netip.MustParseAddr("192.168.2.1"), Interface: 4, Addr: netip.MustParseAddr("192.168.2.2"), Weight: 3},
+		},
+	}}
+
+	var cnt = map[uint32]int{}
+	for i := 0; i < 4000; i++ {
+		src := netip.AddrPortFrom(netip.MustParseAddr("192.168.1.2"), uint16(i))
+		dst := netip.AddrPortFrom(netip.MustParseAddr("10.1.1.1"), 80)
+		hash := route.FlowHash(src, dst, 6)
+
+		e := table.MatchFlow(dst.Addr(), hash)
+		require.Equal(t, e, table.MatchFlow(dst.Addr(), hash))
+		if e.Interface == 3 {
+			require.Equal(t, netip.MustParseAddr("192.168.1.1"), e.Next)
+		} else {
+			require.Equal(t, netip.MustParseAddr("192.168.2.2"), e.Addr)
+		}
+		cnt[e.Interface]++
+	}
+	require.InDelta(t, 3.0, float64(cnt[4])/float64(cnt[3]), 0.6)
+
+	e := table[0]
+	e.NextHops = nil
+	require.Equal(t, e, e.Select(12345))
+}
+
+func Test_Index(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	randAddr := func(v4 bool) netip.Addr {
+		var b [16]byte
+		r.Read(b[:])
+		b[0] = b[0]&0x0f | 0x10 // concentrate, make more overlap
+		if v4 {
+			return netip.AddrFrom4([4]byte(b[:4]))
+		}
+		return netip.AddrFrom16(b)
+	}
+
+	var table route.Table
+	for i := 0; i < 2000; i++ {
+		v4 := i%2 == 0
+		addr := randAddr(v4)
+		e := route.Entry{
+			Dest:      netip.PrefixFrom(addr, r.Intn(addr.BitLen()/2+1)),
+			Interface: uint32(r.Intn(8) + 1),
+			Addr:      randAddr(v4),
+			Metric:    uint32(r.Intn(4)),
+		}
+		if i%50 == 0 {
+			e.Addr = netip.Addr{} // never match
+		}
+		table = append(table, e)
+	}
+	table = append(table,
+		route.Entry{Dest: netip.MustParsePrefix("0.0.0.0/0"), Interface: 1, Addr: netip.MustParseAddr("10.0.0.1"), Metric: 1},
+		route.Entry{Dest: netip.MustParsePrefix("::/0"), Interface: 1, Addr: netip.MustParseAddr("fd00::1")},
+	)
+	table.Sort()
+
+	idx := route.NewIndex(table)
+	fn := func(e route.Entry) bool { return e.Interface%2 == 0 }
+	for i := 0; i < 20000; i++ {
+		dst := randAddr(i%2 == 0)
+		require.Equal(t, table.Match(dst), idx.Match(dst), dst)
+		require.Equal(t, table.MatchFunc(dst, fn), idx.MatchFunc(dst, fn), dst)
+	}
+	for _, dst := range []netip.Addr{
+		netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("2001::1"),
+		netip.MustParseAddr("fe80::1%eth0"), netip.MustParseAddr("::ffff:8.8.8.8"), {},
+	} {
+		require.Equal(t, table.Match(dst), idx.Match(dst), dst)
+	}
+	require.Equal(t, route.Entry{}, route.NewIndex(nil).Match(netip.MustParseAddr("8.8.8.8")))
+
+	t.Run("incremental", func(t *testing.T) {
+		var table, idx = slices.Clone(table), idx
+		for i := 0; i < 500; i++ {
+			old, oldTable := idx, slices.Clone(table)
+
+			e := table[r.Intn(len(table))]
+			if i%2 == 0 {
+				table = slices.DeleteFunc(table, func(r route.Entry) bool {
+					return r.Dest == e.Dest && r.Metric == e.Metric
+				})
+				idx = idx.DeleteFunc(e.Dest, func(r route.Entry) bool { return r.Metric == e.Metric })
+			} else {
+				e.Interface, e.Addr = uint32(r.Intn(8)+1), randAddr(e.Dest.Addr().Is4())
+				table = append(table, e)
+				table.Sort()
+				idx = idx.Insert(e)
+			}
+			require.Equal(t, table, idx.Table())
+
+			for j := 0; j < 20; j++ {
+				dst := randAddr(j%2 == 0)
+				require.Equal(t, table.Match(dst), idx.Match(dst), dst)
+				require.Equal(t, table.MatchFunc(dst, fn), idx.MatchFunc(dst, fn), dst)
+				require.Equal(t, oldTable.Match(dst), old.Match(dst), dst)
+			}
+		}
+	})
+}
+
 func Test_Loopback(t *testing.T) {
 	tb := unmarshal(t, table)
 
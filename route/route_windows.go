@@ -6,42 +6,80 @@ package route
 import (
 	"net/netip"
 
+	"github.com/lysShub/netkit/errorx"
 	netcall "github.com/lysShub/netkit/syscall"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
-// GetTable get ipv4 route entries
-func GetTable() (table Table, err error) {
-	rows, err := winipcfg.GetIPForwardTable2(windows.AF_INET) // todo: support ipv6
+// Get get the route entry to dst, select from GetTable by Src/OIf option,
+// Mark and UID option are ignored, return errorx.NotFound error if dst is
+// unreachable. todo: GetBestRoute2
+func Get(dst netip.Addr, opts ...Option) (Entry, error) {
+	cfg := Options(opts...)
+	table, err := GetTable(opts...)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := table.MatchFunc(dst, func(e Entry) (hit bool) {
+		return (!cfg.src.IsValid() || e.Addr == cfg.src) &&
+			(cfg.oif == 0 || e.Interface == cfg.oif)
+	})
+	if !e.Valid() {
+		return Entry{}, errorx.WrapNotfound(errors.Errorf("not found route to %s", dst.String()))
+	}
+	return e, nil
+}
+
+// GetTable get route entries, default only ipv4, can select family by
+// IPv4/IPv6 option, TableID option is ignored.
+func GetTable(opts ...Option) (table Table, err error) {
+	cfg := Options(opts...)
+	family := winipcfg.AddressFamily(windows.AF_UNSPEC)
+	if !cfg.ipv6 {
+		family = windows.AF_INET
+	} else if !cfg.ipv4 {
+		family = windows.AF_INET6
+	}
+	rows, err := winipcfg.GetIPForwardTable2(family)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	var ifs = map[uint32]*winipcfg.MibIPInterfaceRow{}
+	type ifKey struct {
+		family winipcfg.AddressFamily
+		index  uint32
+	}
+	var ifs = map[ifKey]*winipcfg.MibIPInterfaceRow{}
 	for _, e := range rows {
+		dest := e.DestinationPrefix.Prefix()
 		next := e.NextHop.Addr()
 		if next.IsUnspecified() {
 			next = netip.Addr{}
 		}
 
-		i, has := ifs[e.InterfaceIndex]
+		k := ifKey{family: windows.AF_INET, index: e.InterfaceIndex}
+		if dest.Addr().Is6() {
+			k.family = windows.AF_INET6
+		}
+		i, has := ifs[k]
 		if !has {
 			i = &winipcfg.MibIPInterfaceRow{
-				Family:        windows.AF_INET,
+				Family:        k.family,
 				InterfaceLUID: e.InterfaceLUID,
 			}
 			if err := netcall.GetIpInterfaceEntry(i); err != nil {
 				return nil, err
 			}
-			ifs[e.InterfaceIndex] = i
+			ifs[k] = i
 		}
 		// https://learn.microsoft.com/zh-cn/windows/win32/api/netioapi/ns-netioapi-mib_ipforward_row2
 		e.Metric += i.Metric
 
 		table = append(table, Entry{
-			Dest:      e.DestinationPrefix.Prefix(),
+			Dest:      dest,
 			Next:      next,
 			Interface: e.InterfaceIndex,
 			Metric:    e.Metric,
@@ -49,18 +87,38 @@ func GetTable() (table Table, err error) {
 		})
 	}
 
-	var addrMap = map[uint32]netip.Addr{}
-	addrs, err := getIpAddrs()
-	if err != nil {
-		return nil, err
+	var addrMap = map[uint32][]srcAddr{}
+	if cfg.ipv4 {
+		addrs, err := getIpAddrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range addrs {
+			addrMap[e.Index] = append(addrMap[e.Index], srcAddr{prefix: e.Addr()})
+		}
 	}
-	for _, e := range addrs {
-		addrMap[e.Index] = e.Addr().Addr()
+	if cfg.ipv6 {
+		addrs, err := winipcfg.GetUnicastIPAddressTable(windows.AF_INET6)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, e := range addrs {
+			if e.SkipAsSource || (e.DadState != winipcfg.DadStatePreferred && e.DadState != winipcfg.DadStateDeprecated) {
+				continue
+			}
+			addrMap[e.InterfaceIndex] = append(addrMap[e.InterfaceIndex], srcAddr{
+				prefix:     netip.PrefixFrom(e.Address.Addr().WithZone(""), int(e.OnLinkPrefixLength)),
+				deprecated: e.DadState == winipcfg.DadStateDeprecated,
+				temporary:  e.SuffixOrigin == winipcfg.SuffixOriginRandom,
+			})
+		}
 	}
 	for i, e := range table {
-		a, has := addrMap[uint32(e.Interface)]
-		if has {
-			table[i].Addr = a
+		if cands, has := addrMap[uint32(e.Interface)]; has {
+			table[i].Addrs = sortSource(cands, e.Dest.Addr())
+			if len(table[i].Addrs) > 0 {
+				table[i].Addr = table[i].Addrs[0]
+			}
 		}
 	}
 
@@ -2,3 +2,32 @@
 // +build windows
 
 package route_test
+
+import (
+	"testing"
+
+	"github.com/lysShub/netkit/route"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Family(t *testing.T) {
+	table, err := route.GetTable()
+	require.NoError(t, err)
+	for _, e := range table {
+		require.True(t, e.Dest.Addr().Is4(), e.Dest)
+	}
+
+	table, err = route.GetTable(route.IPv6)
+	require.NoError(t, err)
+	for _, e := range table {
+		require.True(t, e.Dest.Addr().Is6(), e.Dest)
+	}
+
+	table, err = route.GetTable(route.IPv4, route.IPv6)
+	require.NoError(t, err)
+	var v4, v6 bool
+	for _, e := range table {
+		v4, v6 = v4 || e.Dest.Addr().Is4(), v6 || e.Dest.Addr().Is6()
+	}
+	require.True(t, v4 && v6)
+}
@@ -0,0 +1,231 @@
+//go:build linux
+// +build linux
+
+package route
+
+import (
+	"net/netip"
+	"syscall"
+	"unsafe"
+
+	"github.com/lysShub/netkit/errorx"
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Rule policy routing rule, https://man7.org/linux/man-pages/man8/ip-rule.8.html
+//
+// e.g. fwmark based split tunneling:
+//
+//	route.Add(route.Entry{Dest: netip.MustParsePrefix("0.0.0.0/0"), Interface: tunIfi, Table: 100})
+//	route.AddRule(route.Rule{Priority: 1000, Mark: 0x100, Table: 100})
+type Rule struct {
+	// Family unix.AF_INET or unix.AF_INET6, zero means inferred from From/To,
+	// or unix.AF_INET
+	Family uint8 `json:"family"`
+
+	// Priority zero means assigned by kernel when AddRule
+	Priority uint32 `json:"priority"`
+
+	From netip.Prefix `json:"from"`
+	To   netip.Prefix `json:"to"`
+
+	// Mark match fwmark&Mask == Mark, Mask zero means 0xffffffff
+	Mark uint32 `json:"mark,omitempty"`
+	Mask uint32 `json:"mask,omitempty"`
+
+	// IIf/OIf input/output interface name
+	IIf string `json:"iif,omitempty"`
+	OIf string `json:"oif,omitempty"`
+
+	// Table lookup the route table, when Action is FR_ACT_TO_TBL
+	Table uint32 `json:"table"`
+
+	// Action unix.FR_ACT_*, zero means unix.FR_ACT_TO_TBL when AddRule
+	Action uint8 `json:"action"`
+
+	// Invert rule match when selector not match
+	Invert bool `json:"invert,omitempty"`
+}
+
+// fibRuleHdr struct fib_rule_hdr
+type fibRuleHdr struct {
+	Family uint8
+	DstLen uint8
+	SrcLen uint8
+	Tos    uint8
+	Table  uint8
+	Res1   uint8
+	Res2   uint8
+	Action uint8
+	Flags  uint32
+}
+
+const sizeofFibRuleHdr = int(unsafe.Sizeof(fibRuleHdr{}))
+
+// GetRules get policy routing rules by RTM_GETRULE, can select family by
+// IPv4/IPv6 option, table by TableID option.
+func GetRules(opts ...Option) ([]Rule, error) {
+	cfg := Options(opts...)
+	var hdr = fibRuleHdr{Family: unix.AF_UNSPEC}
+	if !cfg.ipv6 {
+		hdr.Family = unix.AF_INET
+	} else if !cfg.ipv4 {
+		hdr.Family = unix.AF_INET6
+	}
+
+	msgs, err := netcall.NetlinkRequest(unix.RTM_GETRULE, unix.NLM_F_DUMP, netcall.StructBytes(&hdr))
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	for i := range msgs {
+		if msgs[i].Header.Type != unix.RTM_NEWRULE {
+			continue
+		}
+		r, err := parseRule(&msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		if cfg.table == 0 || r.Table == cfg.table {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func parseRule(m *syscall.NetlinkMessage) (Rule, error) {
+	if len(m.Data) < sizeofFibRuleHdr {
+		return Rule{}, errors.Errorf("invalid fib_rule_hdr %x", m.Data)
+	}
+	hdr := (*fibRuleHdr)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+	attrs, err := netcall.ParseNetlinkAttr(m.Data[sizeofFibRuleHdr:])
+	if err != nil {
+		return Rule{}, err
+	}
+
+	var r = Rule{
+		Family: hdr.Family,
+		Table:  uint32(hdr.Table),
+		Action: hdr.Action,
+		Invert: hdr.Flags&unix.FIB_RULE_INVERT != 0,
+	}
+	for _, e := range attrs {
+		switch e.Attr.Type {
+		case unix.FRA_PRIORITY:
+			r.Priority = *(*uint32)(unsafe.Pointer(unsafe.SliceData(e.Value)))
+		case unix.FRA_SRC:
+			if addr, ok := netip.AddrFromSlice(e.Value); ok {
+				r.From = netip.PrefixFrom(addr, int(hdr.SrcLen))
+			}
+		case unix.FRA_DST:
+			if addr, ok := netip.AddrFromSlice(e.Value); ok {
+				r.To = netip.PrefixFrom(addr, int(hdr.DstLen))
+			}
+		case unix.FRA_FWMARK:
+			r.Mark = *(*uint32)(unsafe.Pointer(unsafe.SliceData(e.Value)))
+		case unix.FRA_FWMASK:
+			r.Mask = *(*uint32)(unsafe.Pointer(unsafe.SliceData(e.Value)))
+		case unix.FRA_IIFNAME:
+			r.IIf = unix.ByteSliceToString(e.Value)
+		case unix.FRA_OIFNAME:
+			r.OIf = unix.ByteSliceToString(e.Value)
+		case unix.FRA_TABLE:
+			r.Table = *(*uint32)(unsafe.Pointer(unsafe.SliceData(e.Value)))
+		}
+	}
+	return r, nil
+}
+
+// AddRule add policy routing rule by RTM_NEWRULE, return error satisfy
+// errors.Is(err, os.ErrExist) if the rule exist.
+func AddRule(r Rule) error {
+	hdr, attrs, err := ruleMsg(unix.RTM_NEWRULE, r)
+	if err != nil {
+		return err
+	}
+	_, err = netcall.NetlinkRequest(unix.RTM_NEWRULE, unix.NLM_F_CREATE|unix.NLM_F_EXCL, netcall.StructBytes(hdr), attrs...)
+	if errors.Is(err, unix.EEXIST) {
+		return errors.WithMessage(err, "rule exist")
+	}
+	return err
+}
+
+// DelRule delete policy routing rule by RTM_DELRULE, unset fields of r match
+// any, return errorx.NotFound error if not exist.
+func DelRule(r Rule) error {
+	hdr, attrs, err := ruleMsg(unix.RTM_DELRULE, r)
+	if err != nil {
+		return err
+	}
+	_, err = netcall.NetlinkRequest(unix.RTM_DELRULE, 0, netcall.StructBytes(hdr), attrs...)
+	if errors.Is(err, unix.ENOENT) {
+		return errorx.WrapNotfound(errors.New("not found rule"))
+	}
+	return err
+}
+
+func ruleMsg(typ uint16, r Rule) (*fibRuleHdr, []netcall.NetlinkAttr, error) {
+	family := r.Family
+	for _, p := range []netip.Prefix{r.From, r.To} {
+		if !p.IsValid() {
+			continue
+		}
+		f := uint8(unix.AF_INET)
+		if p.Addr().Is6() {
+			f = unix.AF_INET6
+		}
+		if family != 0 && family != f {
+			return nil, nil, errors.Errorf("address %s family not match", p.String())
+		}
+		family = f
+	}
+	if family == 0 {
+		family = unix.AF_INET
+	}
+
+	var hdr = &fibRuleHdr{Family: family, Action: r.Action}
+	if typ == unix.RTM_NEWRULE && hdr.Action == 0 {
+		hdr.Action = unix.FR_ACT_TO_TBL
+	}
+	if r.Invert {
+		hdr.Flags |= unix.FIB_RULE_INVERT
+	}
+
+	var attrs []netcall.NetlinkAttr
+	if r.Priority != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.FRA_PRIORITY, r.Priority))
+	}
+	if r.From.IsValid() {
+		hdr.SrcLen = uint8(r.From.Bits())
+		attrs = append(attrs, netcall.BytesAttr(unix.FRA_SRC, r.From.Masked().Addr().AsSlice()))
+	}
+	if r.To.IsValid() {
+		hdr.DstLen = uint8(r.To.Bits())
+		attrs = append(attrs, netcall.BytesAttr(unix.FRA_DST, r.To.Masked().Addr().AsSlice()))
+	}
+	if r.Mark != 0 || r.Mask != 0 {
+		attrs = append(attrs, netcall.Uint32Attr(unix.FRA_FWMARK, r.Mark))
+		mask := r.Mask
+		if mask == 0 {
+			mask = 0xffffffff
+		}
+		attrs = append(attrs, netcall.Uint32Attr(unix.FRA_FWMASK, mask))
+	}
+	if r.IIf != "" {
+		attrs = append(attrs, netcall.StringAttr(unix.FRA_IIFNAME, r.IIf))
+	}
+	if r.OIf != "" {
+		attrs = append(attrs, netcall.StringAttr(unix.FRA_OIFNAME, r.OIf))
+	}
+	if r.Table != 0 {
+		hdr.Table = unix.RT_TABLE_UNSPEC // by FRA_TABLE
+		if r.Table < 256 {
+			hdr.Table = uint8(r.Table)
+		}
+		attrs = append(attrs, netcall.Uint32Attr(unix.FRA_TABLE, r.Table))
+	}
+	return hdr, attrs, nil
+}
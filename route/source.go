@@ -0,0 +1,141 @@
+package route
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// srcAddr candidate source address of interface
+type srcAddr struct {
+	prefix     netip.Prefix // address and it's prefix length
+	deprecated bool
+	secondary  bool // ipv4 secondary address
+	temporary  bool // ipv6 privacy address, RFC 4941
+}
+
+// sortSource sort candidate source addresses by preference for dst, follow
+// source address selection of RFC 6724 section 5, rule 4(home address) and
+// 5(outgoing interface) are not applicable. return addresses with same family
+// as dst.
+func sortSource(cands []srcAddr, dst netip.Addr) []netip.Addr {
+	dst = dst.WithZone("")
+	cands = slices.DeleteFunc(slices.Clone(cands), func(c srcAddr) bool {
+		return c.prefix.Addr().Is4() != dst.Is4()
+	})
+
+	slices.SortStableFunc(cands, func(a, b srcAddr) int {
+		sa, sb := a.prefix.Addr(), b.prefix.Addr()
+
+		// rule 1: prefer same address
+		if sa == dst {
+			return -1
+		} else if sb == dst {
+			return 1
+		}
+
+		// rule 2: prefer appropriate scope
+		if ca, cb := scope(sa), scope(sb); ca < cb {
+			if ca < scope(dst) {
+				return 1
+			}
+			return -1
+		} else if ca > cb {
+			if cb < scope(dst) {
+				return -1
+			}
+			return 1
+		}
+
+		// rule 3: avoid deprecated addresses
+		if a.deprecated != b.deprecated {
+			if a.deprecated {
+				return 1
+			}
+			return -1
+		}
+
+		// prefer primary address, as linux kernel
+		if a.secondary != b.secondary {
+			if a.secondary {
+				return 1
+			}
+			return -1
+		}
+
+		// rule 6: prefer matching label
+		if la, lb, ld := label(sa), label(sb), label(dst); la == ld && lb != ld {
+			return -1
+		} else if la != ld && lb == ld {
+			return 1
+		}
+
+		// rule 7: prefer temporary addresses
+		if a.temporary != b.temporary {
+			if a.temporary {
+				return -1
+			}
+			return 1
+		}
+
+		// rule 8: use longest matching prefix
+		d := netip.PrefixFrom(dst, dst.BitLen())
+		return commonBits(b.prefix, d) - commonBits(a.prefix, d)
+	})
+
+	var addrs = make([]netip.Addr, 0, len(cands))
+	for _, e := range cands {
+		addrs = append(addrs, e.prefix.Addr())
+	}
+	return addrs
+}
+
+// scope address scope of RFC 6724 section 3.1, ipv4 address as ipv4-mapped
+// ipv6 address, see section 3.2
+func scope(addr netip.Addr) int {
+	const (
+		linkLocal = 0x2
+		siteLocal = 0x5
+		global    = 0xe
+	)
+	switch {
+	case addr.Is6() && addr.IsMulticast():
+		return int(addr.As16()[1] & 0x0f)
+	case addr.IsLoopback(), addr.IsLinkLocalUnicast():
+		return linkLocal
+	case addr.Is6() && sitePrefix.Contains(addr):
+		return siteLocal
+	default:
+		return global
+	}
+}
+
+var sitePrefix = netip.MustParsePrefix("fec0::/10")
+
+// policyTable default policy table of RFC 6724 section 2.1, sorted by prefix
+// length descending
+var policyTable = []struct {
+	prefix netip.Prefix
+	label  int
+}{
+	{netip.MustParsePrefix("::1/128"), 0},
+	{netip.MustParsePrefix("::/96"), 3},
+	{netip.MustParsePrefix("::ffff:0:0/96"), 4},
+	{netip.MustParsePrefix("2001::/32"), 5},
+	{netip.MustParsePrefix("2002::/16"), 2},
+	{netip.MustParsePrefix("3ffe::/16"), 12},
+	{netip.MustParsePrefix("fec0::/10"), 11},
+	{netip.MustParsePrefix("fc00::/7"), 13},
+	{netip.MustParsePrefix("::/0"), 1},
+}
+
+func label(addr netip.Addr) int {
+	if addr.Is4() {
+		addr = netip.AddrFrom16(addr.As16()) // ipv4-mapped
+	}
+	for _, e := range policyTable {
+		if e.prefix.Contains(addr) {
+			return e.label
+		}
+	}
+	return 1
+}
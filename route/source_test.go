@@ -0,0 +1,32 @@
+package route
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sortSource(t *testing.T) {
+	var (
+		public    = netip.MustParsePrefix("2001:db8::1/64")
+		temporary = netip.MustParsePrefix("2001:db8::abcd/64")
+		linkLocal = netip.MustParsePrefix("fe80::1/64")
+	)
+	addrs := sortSource([]srcAddr{
+		{prefix: linkLocal},
+		{prefix: public},
+		{prefix: temporary, temporary: true},
+	}, netip.MustParseAddr("2001:db8:1::1"))
+	require.Equal(t, []netip.Addr{temporary.Addr(), public.Addr(), linkLocal.Addr()}, addrs)
+
+	addrs = sortSource([]srcAddr{
+		{prefix: netip.MustParsePrefix("10.0.0.2/24"), secondary: true},
+		{prefix: netip.MustParsePrefix("10.0.0.1/24"), deprecated: true},
+		{prefix: netip.MustParsePrefix("10.0.0.3/24")},
+		{prefix: public},
+	}, netip.MustParseAddr("8.8.8.8"))
+	require.Equal(t, []netip.Addr{
+		netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1"),
+	}, addrs)
+}
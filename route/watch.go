@@ -0,0 +1,194 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type EventType uint8
+
+const (
+	EventAdd EventType = iota + 1
+
+	// EventReplace the entry replaced exist entry with same dest, metric and table
+	EventReplace
+
+	EventDelete
+
+	// EventOverflow events lost because of receive buffer overflow, should
+	// re-get table by GetTable
+	EventOverflow
+
+	// eventAddr addresses of Entry.Interface changed, only for Snapshot
+	eventAddr
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAdd:
+		return "add"
+	case EventReplace:
+		return "replace"
+	case EventDelete:
+		return "delete"
+	case EventOverflow:
+		return "overflow"
+	case eventAddr:
+		return "addr"
+	default:
+		return "unknown"
+	}
+}
+
+// Event route change event of Watch
+type Event struct {
+	Type  EventType
+	Entry Entry
+}
+
+// Snapshot always-current route table, updated by Watch events
+type Snapshot struct {
+	opts []Option
+
+	mu    sync.RWMutex
+	index *Index
+	table Table // cache of index.Table(), nil after changed
+	err   error
+
+	done chan struct{}
+}
+
+// NewSnapshot get route table and keep it updated until ctx done, e.g:
+//
+//	s, _ := route.NewSnapshot(ctx)
+//	e := s.Match(dst)
+func NewSnapshot(ctx context.Context, opts ...Option) (*Snapshot, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// subscribe before get table, avoid lost change
+	events, err := Watch(ctx, append(slices.Clip(opts), addrEvent)...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	table, err := GetTable(opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var s = &Snapshot{opts: opts, index: NewIndex(table), table: table, done: make(chan struct{})}
+	go func() {
+		defer cancel()
+		s.update(ctx, events)
+	}()
+	return s, nil
+}
+
+func (s *Snapshot) update(ctx context.Context, events <-chan Event) {
+	defer close(s.done)
+	for e := range events {
+		if err := s.apply(e); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if s.err = context.Cause(ctx); s.err == nil {
+		s.err = errors.New("watch closed")
+	}
+	s.mu.Unlock()
+}
+
+func (s *Snapshot) apply(e Event) error {
+	if e.Type == EventOverflow {
+		table, err := GetTable(s.opts...)
+		if err != nil {
+			return err
+		}
+		index := NewIndex(table)
+		s.mu.Lock()
+		s.index, s.table = index, table
+		s.mu.Unlock()
+		return nil
+	} else if e.Type == eventAddr {
+		// re-select source address of entries on the interface
+		index, err := refreshSource(s.Index(), e.Entry.Interface)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if index != s.index {
+			s.index, s.table = index, nil
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	// update index incrementally, only copy nodes on the path of e.Entry.Dest
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.index.DeleteFunc(e.Entry.Dest, func(r Entry) bool {
+		if e.Type == EventReplace {
+			return r.Metric == e.Entry.Metric && r.Table == e.Entry.Table
+		}
+		return sameRoute(r, e.Entry)
+	})
+	if e.Type != EventDelete {
+		index = index.Insert(e.Entry)
+	}
+	if index != s.index {
+		s.index, s.table = index, nil
+	}
+	return nil
+}
+
+func sameRoute(a, b Entry) bool {
+	return a.Dest == b.Dest && a.Next == b.Next && a.Interface == b.Interface &&
+		a.Metric == b.Metric && a.Table == b.Table
+}
+
+// Table current route table, it's immutable
+func (s *Snapshot) Table() Table {
+	s.mu.RLock()
+	table := s.table
+	s.mu.RUnlock()
+	if table != nil {
+		return table
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.table == nil {
+		s.table = s.index.Table()
+	}
+	return s.table
+}
+
+// Index index of current route table, it's immutable, updated by every event
+func (s *Snapshot) Index() *Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+func (s *Snapshot) Match(dst netip.Addr) Entry {
+	return s.Index().Match(dst)
+}
+
+// Done closed after snapshot stop update, because ctx done or error
+func (s *Snapshot) Done() <-chan struct{} { return s.done }
+
+// Err reason of stop update, valid after Done closed
+func (s *Snapshot) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
@@ -0,0 +1,152 @@
+//go:build linux
+// +build linux
+
+package route
+
+import (
+	"context"
+	"os"
+	"slices"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Watch subscribe route change of all tables by RTNLGRP_IPV4_ROUTE and
+// RTNLGRP_IPV6_ROUTE, can select family by IPv4/IPv6 option, table by TableID
+// option. the channel be closed after ctx done or receive failed. also
+// subscribe address change, to refresh cached source address of interfaces.
+func Watch(ctx context.Context, opts ...Option) (<-chan Event, error) {
+	cfg := Options(opts...)
+	var groups uint32
+	if cfg.ipv4 {
+		groups |= unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV4_IFADDR
+	}
+	if cfg.ipv6 {
+		groups |= unix.RTMGRP_IPV6_ROUTE | unix.RTMGRP_IPV6_IFADDR
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}); err != nil {
+		unix.Close(fd)
+		return nil, errors.WithStack(err)
+	}
+	f := os.NewFile(uintptr(fd), "netlink")
+	raw, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	var events = make(chan Event, 64)
+	go func() {
+		defer close(events)
+		stop := context.AfterFunc(ctx, func() { f.Close() })
+		defer func() {
+			if stop() {
+				f.Close()
+			}
+		}()
+
+		var (
+			b     = make([]byte, 64*1024)
+			addrs *ifAddrs // cache, invalidated by address change
+		)
+		for {
+			var n int
+			var operr error
+			if err := raw.Read(func(fd uintptr) (done bool) {
+				n, _, operr = unix.Recvfrom(int(fd), b, 0)
+				return operr != unix.EAGAIN
+			}); err != nil {
+				return // closed
+			}
+
+			var es []Event
+			if operr == unix.ENOBUFS {
+				es, addrs = []Event{{Type: EventOverflow}}, nil
+			} else if operr != nil {
+				return
+			} else if es, addrs, operr = parseEvents(b[:n], cfg, addrs); operr != nil {
+				return
+			}
+			for _, e := range es {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// parseEvents parse route events, addrs is cached interface addresses, nil
+// means need re-get, return the updated cache.
+func parseEvents(b []byte, cfg *Configs, addrs *ifAddrs) ([]Event, *ifAddrs, error) {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var es []Event
+	for i := range msgs {
+		m := &msgs[i]
+		var typ EventType
+		switch m.Header.Type {
+		case unix.RTM_NEWROUTE:
+			typ = EventAdd
+			if m.Header.Flags&unix.NLM_F_REPLACE != 0 {
+				typ = EventReplace
+			}
+		case unix.RTM_DELROUTE:
+			typ = EventDelete
+		case unix.RTM_NEWADDR, unix.RTM_DELADDR:
+			addrs = nil
+			if cfg.addrEvent && len(m.Data) >= unix.SizeofIfAddrmsg {
+				ifa := (*unix.IfAddrmsg)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+				es = append(es, Event{Type: eventAddr, Entry: Entry{Interface: ifa.Index}})
+			}
+			continue
+		default:
+			continue
+		}
+
+		if addrs == nil {
+			if addrs, err = newIfAddrs(); err != nil {
+				return nil, nil, err
+			}
+		}
+		e, ok, err := parseEntry(m, addrs)
+		if err != nil {
+			return nil, nil, err
+		} else if ok && cfg.match(&e) {
+			es = append(es, Event{Type: typ, Entry: e})
+		}
+	}
+	return es, addrs, nil
+}
+
+// refreshSource re-select source address of entries on interface ifi, after
+// addresses of the interface changed.
+func refreshSource(x *Index, ifi uint32) (*Index, error) {
+	addrs, err := newIfAddrs()
+	if err != nil {
+		return nil, err
+	}
+	return x.update(func(e Entry) (Entry, bool) {
+		on := e.Interface == ifi || slices.ContainsFunc(e.NextHops, func(h NextHop) bool {
+			return h.Interface == ifi
+		})
+		if on {
+			setSource(&e, addrs)
+		}
+		return e, on
+	}), nil
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package route
+
+import "context"
+
+func Watch(ctx context.Context, opts ...Option) (<-chan Event, error) {
+	panic("not support")
+}
+
+func refreshSource(x *Index, ifi uint32) (*Index, error) { return x, nil }
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package route
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Watch subscribe route change, todo: NotifyRouteChange2
+func Watch(ctx context.Context, opts ...Option) (<-chan Event, error) {
+	return nil, errors.New("not support")
+}
+
+func refreshSource(x *Index, ifi uint32) (*Index, error) { return x, nil }
@@ -0,0 +1,135 @@
+// Package sampler sample link traffic counters periodically, calculate rates
+// over a sliding window, e.g:
+//
+//	s := sampler.New(ap.Stats, time.Second*10)
+//	go s.Run(ctx, time.Second, func(r sampler.Rate) {
+//		fmt.Println(r.RxBytes, r.TxBytes)
+//	})
+package sampler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+)
+
+// Rate per second rates of counters
+type Rate struct {
+	RxPackets float64 `json:"rx_packets"`
+	TxPackets float64 `json:"tx_packets"`
+	RxBytes   float64 `json:"rx_bytes"`
+	TxBytes   float64 `json:"tx_bytes"`
+	RxErrors  float64 `json:"rx_errors"`
+	TxErrors  float64 `json:"tx_errors"`
+	RxDropped float64 `json:"rx_dropped"`
+	TxDropped float64 `json:"tx_dropped"`
+
+	// Window actual duration the rates calculated over, zero means only one
+	// sample, the rates are invalid.
+	Window time.Duration `json:"window"`
+}
+
+type Sampler struct {
+	get    func() (*netcall.LinkStats, error)
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	samples []sample // ascending by time
+}
+
+type sample struct {
+	time  time.Time
+	stats netcall.LinkStats
+}
+
+// New create sampler, get is counters source, such as TunTap.Stats,
+// route.Entry.Stats, window is duration of rates calculated over.
+func New(get func() (*netcall.LinkStats, error), window time.Duration) *Sampler {
+	return &Sampler{get: get, window: window, now: time.Now}
+}
+
+// Sample take a sample, return rates between the oldest sample within window
+// and the sample.
+func (s *Sampler) Sample() (Rate, error) {
+	stats, err := s.get()
+	if err != nil {
+		return Rate{}, err
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.samples); n > 0 && reset(&s.samples[n-1].stats, stats) {
+		s.samples = s.samples[:0] // device re-created, counters reset
+	}
+	s.samples = append(s.samples, sample{time: now, stats: *stats})
+
+	// keep one sample at or before the window start
+	var i int
+	for i+1 < len(s.samples) && !s.samples[i+1].time.After(now.Add(-s.window)) {
+		i++
+	}
+	s.samples = s.samples[i:]
+	return rate(&s.samples[0], &s.samples[len(s.samples)-1]), nil
+}
+
+// Run sample every interval until ctx done, fn be called with rates of each
+// sample.
+func (s *Sampler) Run(ctx context.Context, interval time.Duration, fn func(Rate)) error {
+	if interval <= 0 {
+		return errors.Errorf("invalid interval %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r, err := s.Sample()
+		if err != nil {
+			return err
+		}
+		fn(r)
+
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func reset(old, new *netcall.LinkStats) bool {
+	return new.RxPackets < old.RxPackets || new.TxPackets < old.TxPackets ||
+		new.RxBytes < old.RxBytes || new.TxBytes < old.TxBytes
+}
+
+func rate(from, to *sample) Rate {
+	d := to.time.Sub(from.time)
+	if d <= 0 {
+		return Rate{}
+	}
+	sec := d.Seconds()
+	per := func(a, b uint64) float64 {
+		if b < a {
+			return 0
+		}
+		return float64(b-a) / sec
+	}
+
+	a, b := &from.stats, &to.stats
+	return Rate{
+		RxPackets: per(a.RxPackets, b.RxPackets),
+		TxPackets: per(a.TxPackets, b.TxPackets),
+		RxBytes:   per(a.RxBytes, b.RxBytes),
+		TxBytes:   per(a.TxBytes, b.TxBytes),
+		RxErrors:  per(a.RxErrors, b.RxErrors),
+		TxErrors:  per(a.TxErrors, b.TxErrors),
+		RxDropped: per(a.RxDropped, b.RxDropped),
+		TxDropped: per(a.TxDropped, b.TxDropped),
+		Window:    d,
+	}
+}
@@ -0,0 +1,85 @@
+package sampler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Sampler(t *testing.T) {
+	var (
+		now   = time.Unix(1000, 0)
+		stats netcall.LinkStats
+	)
+	s := New(func() (*netcall.LinkStats, error) {
+		v := stats
+		return &v, nil
+	}, time.Second*4)
+	s.now = func() time.Time { return now }
+	step := func(rx, tx uint64) Rate {
+		now = now.Add(time.Second)
+		stats.RxPackets += rx
+		stats.RxBytes += rx * 100
+		stats.TxPackets += tx
+		stats.TxBytes += tx * 100
+		r, err := s.Sample()
+		require.NoError(t, err)
+		return r
+	}
+
+	r, err := s.Sample()
+	require.NoError(t, err)
+	require.Zero(t, r.Window)
+
+	t.Run("rate", func(t *testing.T) {
+		r := step(10, 20)
+		require.Equal(t, time.Second, r.Window)
+		require.Equal(t, 10.0, r.RxPackets)
+		require.Equal(t, 20.0, r.TxPackets)
+		require.Equal(t, 1000.0, r.RxBytes)
+		require.Equal(t, 2000.0, r.TxBytes)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			step(10, 20)
+		}
+		r := step(50, 20) // 10 10 10 50 within window
+		require.Equal(t, time.Second*4, r.Window)
+		require.Equal(t, 20.0, r.RxPackets)
+		require.Equal(t, 20.0, r.TxPackets)
+		require.LessOrEqual(t, len(s.samples), 5)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		stats = netcall.LinkStats{}
+		now = now.Add(time.Second)
+		r, err := s.Sample()
+		require.NoError(t, err)
+		require.Zero(t, r.Window)
+
+		r = step(10, 10)
+		require.Equal(t, time.Second, r.Window)
+		require.Equal(t, 10.0, r.RxPackets)
+	})
+
+	t.Run("run", func(t *testing.T) {
+		s := New(func() (*netcall.LinkStats, error) {
+			return &netcall.LinkStats{}, nil
+		}, time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		var n int
+		err := s.Run(ctx, time.Millisecond*10, func(Rate) { n++ })
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.GreaterOrEqual(t, n, 3)
+
+		err = s.Run(context.Background(), 0, func(Rate) {})
+		require.Error(t, err)
+	})
+}
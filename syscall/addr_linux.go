@@ -0,0 +1,140 @@
+//go:build linux
+// +build linux
+
+package syscall
+
+import (
+	"net/netip"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// IfAddr interface address, https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+type IfAddr struct {
+	Index int
+
+	// Prefix local address and prefix length
+	Prefix netip.Prefix
+
+	// Peer remote address of point-to-point link
+	Peer netip.Addr
+
+	Flags uint32 // unix.IFA_F_*
+	Scope uint8  // unix.RT_SCOPE_*
+}
+
+// AddIfAddr add interface address by RTM_NEWADDR
+func AddIfAddr(addr IfAddr) error {
+	msg, attrs, err := ifAddrMsg(addr)
+	if err != nil {
+		return err
+	}
+	if addr.Peer.IsValid() {
+		attrs = append(attrs, BytesAttr(unix.IFA_ADDRESS, addr.Peer.AsSlice()))
+	} else {
+		attrs = append(attrs, BytesAttr(unix.IFA_ADDRESS, addr.Prefix.Addr().AsSlice()))
+	}
+	attrs = append(attrs, Uint32Attr(unix.IFA_FLAGS, addr.Flags))
+
+	_, err = NetlinkRequest(unix.RTM_NEWADDR, unix.NLM_F_CREATE|unix.NLM_F_EXCL, StructBytes(msg), attrs...)
+	return err
+}
+
+// DelIfAddr delete interface address by RTM_DELADDR, only match local address
+// and prefix length
+func DelIfAddr(addr IfAddr) error {
+	msg, attrs, err := ifAddrMsg(addr)
+	if err != nil {
+		return err
+	}
+
+	_, err = NetlinkRequest(unix.RTM_DELADDR, 0, StructBytes(msg), attrs...)
+	return err
+}
+
+func ifAddrMsg(addr IfAddr) (*unix.IfAddrmsg, []NetlinkAttr, error) {
+	if !addr.Prefix.IsValid() {
+		return nil, nil, errors.Errorf("invalid address %s", addr.Prefix.String())
+	} else if addr.Peer.IsValid() && addr.Peer.Is4() != addr.Prefix.Addr().Is4() {
+		return nil, nil, errors.Errorf("peer address %s family not match", addr.Peer.String())
+	}
+
+	var msg = &unix.IfAddrmsg{
+		Family:    unix.AF_INET,
+		Prefixlen: uint8(addr.Prefix.Bits()),
+		Flags:     uint8(addr.Flags),
+		Scope:     addr.Scope,
+		Index:     uint32(addr.Index),
+	}
+	if !addr.Prefix.Addr().Is4() {
+		msg.Family = unix.AF_INET6
+	}
+	return msg, []NetlinkAttr{BytesAttr(unix.IFA_LOCAL, addr.Prefix.Addr().AsSlice())}, nil
+}
+
+// GetIfAddrs get addresses of interface by RTM_GETADDR, ifi 0 means all
+// interfaces.
+func GetIfAddrs(ifi int) ([]IfAddr, error) {
+	msg := unix.IfAddrmsg{Family: unix.AF_UNSPEC}
+	msgs, err := NetlinkRequest(unix.RTM_GETADDR, unix.NLM_F_DUMP, StructBytes(&msg))
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []IfAddr
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWADDR {
+			continue
+		}
+		addr, err := parseIfAddr(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		if ifi == 0 || addr.Index == ifi {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+func parseIfAddr(b []byte) (IfAddr, error) {
+	if len(b) < unix.SizeofIfAddrmsg {
+		return IfAddr{}, errors.Errorf("invalid ifaddrmsg %x", b)
+	}
+	msg := (*unix.IfAddrmsg)(unsafe.Pointer(unsafe.SliceData(b)))
+
+	attrs, err := ParseNetlinkAttr(b[unix.SizeofIfAddrmsg:])
+	if err != nil {
+		return IfAddr{}, err
+	}
+	var (
+		addr = IfAddr{
+			Index: int(msg.Index),
+			Flags: uint32(msg.Flags),
+			Scope: msg.Scope,
+		}
+		local, address netip.Addr
+	)
+	for _, e := range attrs {
+		switch e.Attr.Type {
+		case unix.IFA_LOCAL:
+			local, _ = netip.AddrFromSlice(e.Value)
+		case unix.IFA_ADDRESS:
+			address, _ = netip.AddrFromSlice(e.Value)
+		case unix.IFA_FLAGS:
+			addr.Flags = attrUint32(e.Value)
+		}
+	}
+
+	if local.IsValid() {
+		addr.Prefix = netip.PrefixFrom(local, int(msg.Prefixlen))
+		if address.IsValid() && address != local {
+			addr.Peer = address
+		}
+	} else {
+		addr.Prefix = netip.PrefixFrom(address, int(msg.Prefixlen))
+	}
+	return addr, nil
+}
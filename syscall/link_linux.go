@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+package syscall
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// LinkAttrs link attributes, https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+type LinkAttrs struct {
+	Index      int
+	Name       string
+	Type       uint16 // unix.ARPHRD_*
+	Flags      uint32 // unix.IFF_*
+	MTU        uint32
+	TxQueueLen uint32
+	Hardware   net.HardwareAddr
+	OperState  uint8 // unix.IF_OPER_*
+	Carrier    bool
+
+	NumTxQueues uint32
+	NumRxQueues uint32
+
+	// Attrs raw attributes, include not parsed attributes
+	Attrs []syscall.NetlinkRouteAttr
+}
+
+// Attr get raw attribute value
+func (l *LinkAttrs) Attr(typ uint16) ([]byte, bool) {
+	for _, e := range l.Attrs {
+		if e.Attr.Type == typ {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetLinkAttrs get attributes of link by RTM_GETLINK
+func GetLinkAttrs(ifi int) (*LinkAttrs, error) {
+	msg := unix.IfInfomsg{Family: unix.AF_UNSPEC, Index: int32(ifi)}
+	msgs, err := NetlinkRequest(unix.RTM_GETLINK, 0, StructBytes(&msg))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWLINK {
+			continue
+		}
+		return parseLink(m.Data)
+	}
+	return nil, errors.Errorf("not found link %d", ifi)
+}
+
+func parseLink(b []byte) (*LinkAttrs, error) {
+	if len(b) < unix.SizeofIfInfomsg {
+		return nil, errors.Errorf("invalid ifinfomsg %x", b)
+	}
+	msg := (*unix.IfInfomsg)(unsafe.Pointer(unsafe.SliceData(b)))
+
+	attrs, err := ParseNetlinkAttr(b[unix.SizeofIfInfomsg:])
+	if err != nil {
+		return nil, err
+	}
+	var l = &LinkAttrs{
+		Index: int(msg.Index),
+		Type:  msg.Type,
+		Flags: msg.Flags,
+		Attrs: attrs,
+	}
+	for _, e := range attrs {
+		switch e.Attr.Type {
+		case unix.IFLA_IFNAME:
+			l.Name = unix.ByteSliceToString(e.Value)
+		case unix.IFLA_MTU:
+			l.MTU = attrUint32(e.Value)
+		case unix.IFLA_TXQLEN:
+			l.TxQueueLen = attrUint32(e.Value)
+		case unix.IFLA_ADDRESS:
+			l.Hardware = net.HardwareAddr(e.Value)
+		case unix.IFLA_OPERSTATE:
+			if len(e.Value) > 0 {
+				l.OperState = e.Value[0]
+			}
+		case unix.IFLA_CARRIER:
+			l.Carrier = len(e.Value) > 0 && e.Value[0] != 0
+		case unix.IFLA_NUM_TX_QUEUES:
+			l.NumTxQueues = attrUint32(e.Value)
+		case unix.IFLA_NUM_RX_QUEUES:
+			l.NumRxQueues = attrUint32(e.Value)
+		}
+	}
+	return l, nil
+}
+
+// SetLinkAttr set attributes of link by RTM_SETLINK, such as:
+//
+//	SetLinkAttr(ifi, Uint32Attr(unix.IFLA_MTU, 9000))
+func SetLinkAttr(ifi int, attrs ...NetlinkAttr) error {
+	msg := unix.IfInfomsg{Family: unix.AF_UNSPEC, Index: int32(ifi)}
+	_, err := NetlinkRequest(unix.RTM_SETLINK, 0, StructBytes(&msg), attrs...)
+	return err
+}
+
+func SetLinkMTU(ifi int, mtu uint32) error {
+	return SetLinkAttr(ifi, Uint32Attr(unix.IFLA_MTU, mtu))
+}
+
+func SetLinkTxQueueLen(ifi int, qlen uint32) error {
+	return SetLinkAttr(ifi, Uint32Attr(unix.IFLA_TXQLEN, qlen))
+}
+
+func attrUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return *(*uint32)(unsafe.Pointer(unsafe.SliceData(b)))
+}
+
+// SetLinkNetNS move link to network namespace by IFLA_NET_NS_FD, the link's
+// address will be flushed and be set down.
+func SetLinkNetNS(ifi int, ns *NetNS) error {
+	return SetLinkAttr(ifi, Uint32Attr(unix.IFLA_NET_NS_FD, uint32(ns.Fd())))
+}
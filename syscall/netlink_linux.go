@@ -0,0 +1,191 @@
+//go:build linux
+// +build linux
+
+package syscall
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// ParseNetlinkAttr parse netlink attributes(struct rtattr), b not include
+// the fixed header of message, such as struct ndmsg.
+func ParseNetlinkAttr(b []byte) ([]syscall.NetlinkRouteAttr, error) {
+	var attrs []syscall.NetlinkRouteAttr
+	for len(b) >= unix.SizeofRtAttr {
+		n := int(binary.NativeEndian.Uint16(b[0:]))
+		if n < unix.SizeofRtAttr || n > len(b) {
+			return nil, errors.Errorf("invalid netlink attribute length %d", n)
+		}
+
+		attrs = append(attrs, syscall.NetlinkRouteAttr{
+			Attr: syscall.RtAttr{
+				Len:  uint16(n),
+				Type: binary.NativeEndian.Uint16(b[2:]),
+			},
+			Value: b[unix.SizeofRtAttr:n],
+		})
+		b = b[min(rtaAlign(n), len(b)):]
+	}
+	return attrs, nil
+}
+
+func rtaAlign(n int) int {
+	return (n + unix.RTA_ALIGNTO - 1) & ^(unix.RTA_ALIGNTO - 1)
+}
+
+// NetlinkAttr netlink attribute(struct rtattr) of request
+type NetlinkAttr struct {
+	Type  uint16
+	Value []byte
+
+	// Nested nested attributes, Value will be ignored if not empty
+	Nested []NetlinkAttr
+}
+
+func BytesAttr(typ uint16, v []byte) NetlinkAttr { return NetlinkAttr{Type: typ, Value: v} }
+
+func Uint8Attr(typ uint16, v uint8) NetlinkAttr { return NetlinkAttr{Type: typ, Value: []byte{v}} }
+
+func Uint32Attr(typ uint16, v uint32) NetlinkAttr {
+	return NetlinkAttr{Type: typ, Value: binary.NativeEndian.AppendUint32(nil, v)}
+}
+
+// StringAttr null-terminated string attribute
+func StringAttr(typ uint16, v string) NetlinkAttr {
+	return NetlinkAttr{Type: typ, Value: append([]byte(v), 0)}
+}
+
+func NestedAttr(typ uint16, attrs ...NetlinkAttr) NetlinkAttr {
+	return NetlinkAttr{Type: typ, Nested: attrs}
+}
+
+// Len aligned attribute size
+func (a NetlinkAttr) Len() int {
+	return rtaAlign(a.len())
+}
+
+func (a NetlinkAttr) len() int {
+	if len(a.Nested) > 0 {
+		n := unix.SizeofRtAttr
+		for _, e := range a.Nested {
+			n += e.Len()
+		}
+		return n
+	}
+	return unix.SizeofRtAttr + len(a.Value)
+}
+
+func (a NetlinkAttr) encode(b []byte) int {
+	binary.NativeEndian.PutUint16(b[0:], uint16(a.len()))
+	binary.NativeEndian.PutUint16(b[2:], a.Type)
+	if len(a.Nested) > 0 {
+		n := unix.SizeofRtAttr
+		for _, e := range a.Nested {
+			n += e.encode(b[n:])
+		}
+	} else {
+		copy(b[unix.SizeofRtAttr:], a.Value)
+	}
+	return a.Len()
+}
+
+// StructBytes memory of fixed header struct, such as unix.IfInfomsg
+func StructBytes[T any](v *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(v)), unsafe.Sizeof(*v))
+}
+
+var netlinkSeq atomic.Uint32
+
+const netlinkTimeout = time.Second * 5
+
+// NetlinkRequest send NETLINK_ROUTE request and receive response, msg is the
+// fixed header of message, such as unix.IfInfomsg. return response messages,
+// not include NLMSG_DONE and ack, error of NLMSG_ERROR is unix.Errno. request
+// without unix.NLM_F_DUMP will wait ack.
+func NetlinkRequest(typ, flags uint16, msg []byte, attrs ...NetlinkAttr) ([]syscall.NetlinkMessage, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer unix.Close(fd)
+
+	tv := unix.NsecToTimeval(netlinkTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	dump := flags&unix.NLM_F_DUMP == unix.NLM_F_DUMP
+	flags |= unix.NLM_F_REQUEST
+	if !dump {
+		flags |= unix.NLM_F_ACK
+	}
+
+	n := unix.NLMSG_HDRLEN + rtaAlign(len(msg))
+	for _, e := range attrs {
+		n += e.Len()
+	}
+	var b = make([]byte, n)
+	seq := netlinkSeq.Add(1)
+	*(*unix.NlMsghdr)(unsafe.Pointer(&b[0])) = unix.NlMsghdr{
+		Len:   uint32(n),
+		Type:  typ,
+		Flags: flags,
+		Seq:   seq,
+	}
+	i := unix.NLMSG_HDRLEN + copy(b[unix.NLMSG_HDRLEN:], msg)
+	i = rtaAlign(i)
+	for _, e := range attrs {
+		i += e.encode(b[i:])
+	}
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var resp []syscall.NetlinkMessage
+	var rb = make([]byte, max(unix.Getpagesize(), 32*1024))
+	for {
+		n, _, err := unix.Recvfrom(fd, rb, 0)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rb[:n])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return resp, nil
+			case unix.NLMSG_NOOP:
+				continue
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < unix.SizeofNlMsgerr {
+					return nil, errors.Errorf("invalid nlmsgerr %x", m.Data)
+				}
+				e := (*unix.NlMsgerr)(unsafe.Pointer(unsafe.SliceData(m.Data)))
+				if e.Error != 0 {
+					return nil, errors.WithStack(unix.Errno(-e.Error))
+				}
+				return resp, nil // ack
+			default:
+				// copy, rb will be reused
+				m.Data = append([]byte{}, m.Data...)
+				resp = append(resp, m)
+			}
+		}
+	}
+}
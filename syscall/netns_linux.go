@@ -0,0 +1,89 @@
+//go:build linux
+// +build linux
+
+package syscall
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// NetNS network namespace handle, https://man7.org/linux/man-pages/man7/network_namespaces.7.html
+type NetNS struct {
+	fd int
+}
+
+// NetNSDir directory of named network namespace, created by `ip netns add`
+const NetNSDir = "/var/run/netns"
+
+// OpenNetNS open network namespace by path, such as /proc/<pid>/ns/net, or by
+// name(not contain '/') under NetNSDir.
+func OpenNetNS(path string) (*NetNS, error) {
+	if !strings.ContainsRune(path, '/') {
+		path = filepath.Join(NetNSDir, path)
+	}
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.WithStack(&os.PathError{Op: "open", Path: path, Err: err})
+	}
+	return &NetNS{fd: fd}, nil
+}
+
+// NetNSFromFd create NetNS by duplicate fd, caller still own the fd.
+func NetNSFromFd(fd int) (*NetNS, error) {
+	nfd, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &NetNS{fd: nfd}, nil
+}
+
+// CurrentNetNS network namespace of current thread
+func CurrentNetNS() (*NetNS, error) {
+	return OpenNetNS("/proc/thread-self/ns/net")
+}
+
+func (n *NetNS) Fd() int { return n.fd }
+
+func (n *NetNS) Dup() (*NetNS, error) { return NetNSFromFd(n.fd) }
+
+func (n *NetNS) Close() error { return errors.WithStack(unix.Close(n.fd)) }
+
+// Do run fn inside the network namespace, fn run on a new locked os thread,
+// so the caller's thread stay in its own namespace. socket(include netlink)
+// created by fn belong to the namespace.
+func (n *NetNS) Do(fn func() error) (err error) {
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+
+		cur, e := unix.Open("/proc/thread-self/ns/net", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if e != nil {
+			runtime.UnlockOSThread()
+			err = errors.WithStack(e)
+			return
+		}
+		defer unix.Close(cur)
+
+		if e := unix.Setns(n.fd, unix.CLONE_NEWNET); e != nil {
+			runtime.UnlockOSThread()
+			err = errors.WithStack(e)
+			return
+		}
+		err = fn()
+
+		// if can't restore, keep thread locked, runtime will terminate the
+		// thread after goroutine exit
+		if unix.Setns(cur, unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	<-done
+	return err
+}
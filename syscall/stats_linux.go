@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package syscall
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// GetLinkStats get traffic counters of link by RTM_GETLINK IFLA_STATS64, if
+// kernel not support, fallback to /sys/class/net/<name>/statistics, then 32
+// bits IFLA_STATS. sysfs belong to the network namespace that mounted it, so
+// it's used only if the ifindex of sysfs is same.
+func GetLinkStats(ifi int) (*LinkStats, error) {
+	attrs, err := GetLinkAttrs(ifi)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := attrs.Attr(unix.IFLA_STATS64); ok {
+		return parseLinkStats(b, 8)
+	} else if s, err := sysfsLinkStats(attrs.Name, ifi); err == nil {
+		return s, nil
+	} else if b, ok := attrs.Attr(unix.IFLA_STATS); ok {
+		return parseLinkStats(b, 4)
+	}
+	return nil, errors.Errorf("link %d without stats", ifi)
+}
+
+// parseLinkStats parse struct rtnl_link_stats64(size 8) or rtnl_link_stats
+// (size 4), only the leading counters
+func parseLinkStats(b []byte, size int) (*LinkStats, error) {
+	const n = 8
+	if len(b) < n*size {
+		return nil, errors.Errorf("invalid rtnl_link_stats %x", b)
+	}
+
+	var vs [n]uint64
+	for i := range vs {
+		if size == 8 {
+			vs[i] = binary.NativeEndian.Uint64(b[i*size:])
+		} else {
+			vs[i] = uint64(binary.NativeEndian.Uint32(b[i*size:]))
+		}
+	}
+	return &LinkStats{
+		RxPackets: vs[0], TxPackets: vs[1],
+		RxBytes: vs[2], TxBytes: vs[3],
+		RxErrors: vs[4], TxErrors: vs[5],
+		RxDropped: vs[6], TxDropped: vs[7],
+	}, nil
+}
+
+func sysfsLinkStats(name string, ifi int) (*LinkStats, error) {
+	read := func(file string) (uint64, error) {
+		b, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/%s", name, file))
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		return v, errors.WithStack(err)
+	}
+	if idx, err := read("ifindex"); err != nil {
+		return nil, err
+	} else if idx != uint64(ifi) {
+		return nil, errors.Errorf("sysfs of other network namespace")
+	}
+
+	var s = &LinkStats{}
+	for _, e := range []struct {
+		file string
+		v    *uint64
+	}{
+		{"rx_packets", &s.RxPackets}, {"tx_packets", &s.TxPackets},
+		{"rx_bytes", &s.RxBytes}, {"tx_bytes", &s.TxBytes},
+		{"rx_errors", &s.RxErrors}, {"tx_errors", &s.TxErrors},
+		{"rx_dropped", &s.RxDropped}, {"tx_dropped", &s.TxDropped},
+	} {
+		v, err := read("statistics/" + e.file)
+		if err != nil {
+			return nil, err
+		}
+		*e.v = v
+	}
+	return s, nil
+}
@@ -4,7 +4,16 @@
 package syscall_test
 
 import (
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/lysShub/netkit/syscall"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func Test_IoctlTSO(t *testing.T) {
@@ -30,3 +39,63 @@ func Test_IoctlTSO(t *testing.T) {
 	// fmt.Println(o)
 	// require.Equal(t, !init, o)
 }
+
+func Test_LinkAttrs(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+
+	attrs, err := syscall.GetLinkAttrs(lo.Index)
+	require.NoError(t, err)
+	require.Equal(t, "lo", attrs.Name)
+	require.Equal(t, lo.MTU, int(attrs.MTU))
+	require.Equal(t, uint16(unix.ARPHRD_LOOPBACK), attrs.Type)
+	require.NotZero(t, attrs.Flags&unix.IFF_UP)
+
+	v, ok := attrs.Attr(unix.IFLA_IFNAME)
+	require.True(t, ok)
+	require.Equal(t, "lo\x00", string(v))
+
+	_, err = syscall.GetLinkAttrs(0xffff)
+	require.ErrorIs(t, err, unix.ENODEV)
+}
+
+func Test_IfAddrs(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+
+	addrs, err := syscall.GetIfAddrs(lo.Index)
+	require.NoError(t, err)
+	require.Contains(t, addrs, syscall.IfAddr{
+		Index:  lo.Index,
+		Prefix: netip.MustParsePrefix("127.0.0.1/8"),
+		Flags:  unix.IFA_F_PERMANENT,
+		Scope:  unix.RT_SCOPE_HOST,
+	})
+}
+
+func Test_LinkStats(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+
+	old, err := syscall.GetLinkStats(lo.Index)
+	require.NoError(t, err)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	for i := 0; i < 10; i++ {
+		_, err = conn.WriteTo(make([]byte, 100), conn.LocalAddr())
+		require.NoError(t, err)
+	}
+
+	new, err := syscall.GetLinkStats(lo.Index)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, new.TxPackets-old.TxPackets, uint64(10))
+	require.GreaterOrEqual(t, new.TxBytes-old.TxBytes, uint64(10*100))
+
+	b, err := os.ReadFile("/sys/class/net/lo/statistics/tx_packets")
+	require.NoError(t, err)
+	n, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, new.TxPackets)
+}
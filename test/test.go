@@ -271,6 +271,64 @@ func BuildICMP(t require.TestingT, src, dst netip.Addr, typ header.ICMPv4Type, m
 	return iphdr
 }
 
+// BuildIP build a ipv4/ipv6 packet with tcp or udp payload, checksums are calculated.
+// seq and flags only used by tcp.
+func BuildIP(t require.TestingT, src, dst netip.AddrPort, proto tcpip.TransportProtocolNumber, seq uint32, flags header.TCPFlags, payload []byte) []byte {
+	require.Equal(t, src.Addr().Is4(), dst.Addr().Is4())
+
+	var l4 []byte
+	switch proto {
+	case header.TCPProtocolNumber:
+		l4 = make([]byte, header.TCPMinimumSize+len(payload))
+		header.TCP(l4).Encode(&header.TCPFields{
+			SrcPort: src.Port(), DstPort: dst.Port(), SeqNum: seq, AckNum: 1,
+			DataOffset: header.TCPMinimumSize, Flags: flags, WindowSize: 0xffff,
+		})
+	case header.UDPProtocolNumber:
+		l4 = make([]byte, header.UDPMinimumSize+len(payload))
+		header.UDP(l4).Encode(&header.UDPFields{SrcPort: src.Port(), DstPort: dst.Port(), Length: uint16(len(l4))})
+	default:
+		panic(proto)
+	}
+	copy(l4[len(l4)-len(payload):], payload)
+
+	var (
+		b  []byte
+		ip header.Network
+	)
+	if src.Addr().Is4() {
+		b = make([]byte, header.IPv4MinimumSize+len(l4))
+		ip4 := header.IPv4(b)
+		ip4.Encode(&header.IPv4Fields{
+			TotalLength: uint16(len(b)), ID: 1, TTL: 64, Protocol: uint8(proto),
+			SrcAddr: tcpip.AddrFrom4(src.Addr().As4()), DstAddr: tcpip.AddrFrom4(dst.Addr().As4()),
+		})
+		ip4.SetChecksum(^ip4.CalculateChecksum())
+		ip = ip4
+	} else {
+		b = make([]byte, header.IPv6MinimumSize+len(l4))
+		ip6 := header.IPv6(b)
+		ip6.Encode(&header.IPv6Fields{
+			PayloadLength: uint16(len(l4)), TransportProtocol: proto, HopLimit: 64,
+			SrcAddr: tcpip.AddrFrom16(src.Addr().As16()), DstAddr: tcpip.AddrFrom16(dst.Addr().As16()),
+		})
+		ip = ip6
+	}
+	copy(ip.Payload(), l4)
+
+	l4 = ip.Payload()
+	sum := header.PseudoHeaderChecksum(proto, ip.SourceAddress(), ip.DestinationAddress(), uint16(len(l4)))
+	sum = ^checksum.Checksum(l4, sum)
+	if proto == header.TCPProtocolNumber {
+		header.TCP(l4).SetChecksum(sum)
+	} else {
+		header.UDP(l4).SetChecksum(sum)
+	}
+
+	ValidIP(t, b)
+	return b
+}
+
 func PingOnce(t *testing.T, dst string) {
 	pinger, err := ping.NewPinger(dst)
 	require.NoError(t, err)
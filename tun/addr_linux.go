@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"net/netip"
+
+	netcall "github.com/lysShub/netkit/syscall"
+	"golang.org/x/sys/unix"
+)
+
+// AddrOption option of AddAddr
+type AddrOption func(*netcall.IfAddr)
+
+// NoDAD disable ipv6 duplicate address detection, the address usable immediately
+func NoDAD(a *netcall.IfAddr) { a.Flags |= unix.IFA_F_NODAD }
+
+// Peer set remote address of point-to-point link
+func Peer(addr netip.Addr) AddrOption {
+	return func(a *netcall.IfAddr) { a.Peer = addr }
+}
+
+// AddAddr add ipv4/ipv6 address to device, support multiple addresses, such as:
+//
+//	AddAddr(netip.MustParsePrefix("fd00::1/64"), tun.NoDAD)
+//	AddAddr(netip.MustParsePrefix("10.0.0.1/32"), tun.Peer(netip.MustParseAddr("10.0.0.2")))
+func (t *TunTap) AddAddr(addr netip.Prefix, opts ...AddrOption) error {
+	return t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+
+		var a = netcall.IfAddr{Index: ifi, Prefix: addr}
+		for _, fn := range opts {
+			fn(&a)
+		}
+		return netcall.AddIfAddr(a)
+	})
+}
+
+func (t *TunTap) DelAddr(addr netip.Prefix) error {
+	return t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		return netcall.DelIfAddr(netcall.IfAddr{Index: ifi, Prefix: addr})
+	})
+}
+
+// Addrs get all addresses of device, include ipv6 link-local address
+func (t *TunTap) Addrs() (addrs []netcall.IfAddr, err error) {
+	err = t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		addrs, err = netcall.GetIfAddrs(ifi)
+		return err
+	})
+	return addrs, err
+}
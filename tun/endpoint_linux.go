@@ -0,0 +1,246 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// Endpoint gvisor stack.LinkEndpoint of TunTap, used to run userspace tcp/ip
+// stack on the device, kernel is the peer of the stack. for tap device, the
+// stack should register arp.NewProtocol to resolve ipv4 address.
+//
+// e.g:
+//
+//	ep, _ := tun.NewEndpoint(ap, nil)
+//	st.CreateNIC(1, ep)
+type Endpoint struct {
+	tap  *TunTap
+	mtu  uint32
+	addr tcpip.LinkAddress
+
+	mu         sync.RWMutex
+	dispatcher stack.NetworkDispatcher
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+var _ stack.LinkEndpoint = (*Endpoint)(nil)
+
+// NewEndpoint create Endpoint of device, the device must be IFF_NO_PI. hw is
+// hardware address of the stack for tap device, it should be different with
+// device's, nil means random generate, ignored for tun device. for IFF_VNET_HDR
+// device, the received GSO super-packet will be segmented before deliver.
+func NewEndpoint(t *TunTap, hw net.HardwareAddr) (*Endpoint, error) {
+	if t.flags&unix.IFF_NO_PI == 0 {
+		return nil, errors.New("require IFF_NO_PI device")
+	}
+	mtu, err := t.MTU()
+	if err != nil {
+		return nil, err
+	}
+
+	var e = &Endpoint{tap: t, mtu: uint32(mtu)}
+	if !t.tun {
+		if hw == nil {
+			hw = make(net.HardwareAddr, 6)
+			if _, err := rand.Read(hw); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			hw[0] = hw[0]&^0x01 | 0x02 // unicast, locally administered
+		} else if len(hw) != 6 {
+			return nil, errors.Errorf("invalid hardware address %s", hw.String())
+		}
+		e.addr = tcpip.LinkAddress(hw)
+	}
+	return e, nil
+}
+
+// MTU implements stack.LinkEndpoint, is the device's mtu when NewEndpoint
+func (e *Endpoint) MTU() uint32 { return e.mtu }
+
+// MaxHeaderLength implements stack.LinkEndpoint.
+func (e *Endpoint) MaxHeaderLength() uint16 {
+	if e.tap.tun {
+		return 0
+	}
+	return header.EthernetMinimumSize
+}
+
+// LinkAddress implements stack.LinkEndpoint.
+func (e *Endpoint) LinkAddress() tcpip.LinkAddress { return e.addr }
+
+// Capabilities implements stack.LinkEndpoint.
+func (e *Endpoint) Capabilities() stack.LinkEndpointCapabilities {
+	if e.tap.tun {
+		return 0
+	}
+	return stack.CapabilityResolutionRequired
+}
+
+// Attach implements stack.LinkEndpoint, start dispatch loop, nil dispatcher
+// stop it.
+func (e *Endpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.dispatcher = dispatcher
+	if dispatcher != nil {
+		var ctx context.Context
+		ctx, e.cancel = context.WithCancel(context.Background())
+		e.wg.Add(1)
+		go e.dispatchLoop(ctx, dispatcher)
+	}
+}
+
+// IsAttached implements stack.LinkEndpoint.
+func (e *Endpoint) IsAttached() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.dispatcher != nil
+}
+
+// Wait implements stack.LinkEndpoint, wait dispatch loop exit.
+func (e *Endpoint) Wait() { e.wg.Wait() }
+
+// ARPHardwareType implements stack.LinkEndpoint.
+func (e *Endpoint) ARPHardwareType() header.ARPHardwareType {
+	if e.tap.tun {
+		return header.ARPHardwareNone
+	}
+	return header.ARPHardwareEther
+}
+
+// AddHeader implements stack.LinkEndpoint.
+func (e *Endpoint) AddHeader(pkt stack.PacketBufferPtr) {
+	if e.tap.tun {
+		return
+	}
+	eth := header.Ethernet(pkt.LinkHeader().Push(header.EthernetMinimumSize))
+	eth.Encode(&header.EthernetFields{
+		SrcAddr: pkt.EgressRoute.LocalLinkAddress,
+		DstAddr: pkt.EgressRoute.RemoteLinkAddress,
+		Type:    pkt.NetworkProtocolNumber,
+	})
+}
+
+// ParseHeader implements stack.LinkEndpoint.
+func (e *Endpoint) ParseHeader(pkt stack.PacketBufferPtr) bool {
+	if e.tap.tun {
+		return true
+	}
+	_, ok := pkt.LinkHeader().Consume(header.EthernetMinimumSize)
+	return ok
+}
+
+// WritePackets implements stack.LinkEndpoint, write packets to device.
+func (e *Endpoint) WritePackets(pkts stack.PacketBufferList) (int, tcpip.Error) {
+	var n int
+	for _, pkt := range pkts.AsSlice() {
+		var err error
+		if e.tap.VnetHdr() {
+			v := pkt.ToView()
+			_, err = e.tap.Write(context.Background(), v.AsSlice())
+			v.Release()
+		} else {
+			_, err = e.tap.write(context.Background(), pkt.AsSlices())
+		}
+		if err != nil {
+			if n == 0 {
+				if errors.Is(err, os.ErrClosed) {
+					return 0, &tcpip.ErrClosedForSend{}
+				}
+				return 0, &tcpip.ErrAborted{}
+			}
+			break
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (e *Endpoint) dispatchLoop(ctx context.Context, dispatcher stack.NetworkDispatcher) {
+	defer e.wg.Done()
+
+	// max frame size: GSO super-packet up to 64KB, plus link and vnet header
+	size := 0xffff + int(e.MaxHeaderLength())
+	if e.tap.VnetHdr() {
+		size += VirtioNetHdrSize
+	}
+	var b = make([]byte, size)
+	for {
+		hdr, n, err := e.read(ctx, b)
+		if err != nil {
+			return // closed, ctx done or device error
+		}
+
+		// for IFF_VNET_HDR device, the GSO super-packet be split to segments
+		segs, err := e.tap.Segment(hdr, b[:n])
+		if err != nil {
+			continue // invalid packet
+		}
+		for _, seg := range segs {
+			pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+				Payload: buffer.MakeWithData(seg),
+			})
+			if proto, ok := e.parse(pkt, seg); ok {
+				dispatcher.DeliverNetworkPacket(proto, pkt)
+			}
+			pkt.DecRef()
+		}
+	}
+}
+
+// read read a packet, with virtio_net_hdr for IFF_VNET_HDR device
+func (e *Endpoint) read(ctx context.Context, b []byte) (VirtioNetHdr, int, error) {
+	if e.tap.VnetHdr() {
+		return e.tap.ReadGSO(ctx, b)
+	}
+	n, err := e.tap.Read(ctx, b)
+	return VirtioNetHdr{}, n, err
+}
+
+func (e *Endpoint) parse(pkt stack.PacketBufferPtr, b []byte) (tcpip.NetworkProtocolNumber, bool) {
+	if e.tap.tun {
+		switch header.IPVersion(b) {
+		case header.IPv4Version:
+			return header.IPv4ProtocolNumber, true
+		case header.IPv6Version:
+			return header.IPv6ProtocolNumber, true
+		default:
+			return 0, false
+		}
+	}
+
+	if !e.ParseHeader(pkt) {
+		return 0, false
+	}
+	eth := header.Ethernet(pkt.LinkHeader().Slice())
+	switch dst := eth.DestinationAddress(); {
+	case dst == header.EthernetBroadcastAddress:
+		pkt.PktType = tcpip.PacketBroadcast
+	case header.IsMulticastEthernetAddress(dst):
+		pkt.PktType = tcpip.PacketMulticast
+	case dst == e.addr:
+		pkt.PktType = tcpip.PacketHost
+	default:
+		pkt.PktType = tcpip.PacketOtherHost
+	}
+	return eth.Type(), true
+}
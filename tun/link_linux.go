@@ -0,0 +1,127 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"net"
+
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Index interface index of device
+func (t *TunTap) Index() (ifi int, err error) {
+	err = t.do(func() error {
+		ifi, err = t.index()
+		return err
+	})
+	return ifi, err
+}
+
+func (t *TunTap) index() (int, error) {
+	ifi, err := net.InterfaceByName(t.name)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return ifi.Index, nil
+}
+
+// LinkAttrs get link attributes of device
+func (t *TunTap) LinkAttrs() (attrs *netcall.LinkAttrs, err error) {
+	err = t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		attrs, err = netcall.GetLinkAttrs(ifi)
+		return err
+	})
+	return attrs, err
+}
+
+func (t *TunTap) MTU() (int, error) {
+	attrs, err := t.LinkAttrs()
+	if err != nil {
+		return 0, err
+	}
+	return int(attrs.MTU), nil
+}
+
+func (t *TunTap) SetMTU(mtu int) error {
+	if mtu <= 0 {
+		return errors.Errorf("invalid mtu %d", mtu)
+	}
+	return t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		return netcall.SetLinkMTU(ifi, uint32(mtu))
+	})
+}
+
+func (t *TunTap) TxQueueLen() (int, error) {
+	attrs, err := t.LinkAttrs()
+	if err != nil {
+		return 0, err
+	}
+	return int(attrs.TxQueueLen), nil
+}
+
+func (t *TunTap) SetTxQueueLen(qlen int) error {
+	if qlen < 0 {
+		return errors.Errorf("invalid txqueuelen %d", qlen)
+	}
+	return t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		return netcall.SetLinkTxQueueLen(ifi, uint32(qlen))
+	})
+}
+
+// Stats get traffic counters of device, rx is packets written to device,
+// tx is packets read from device.
+func (t *TunTap) Stats() (stats *netcall.LinkStats, err error) {
+	err = t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		stats, err = netcall.GetLinkStats(ifi)
+		return err
+	})
+	return stats, err
+}
+
+func (t *TunTap) Carrier() (bool, error) {
+	attrs, err := t.LinkAttrs()
+	if err != nil {
+		return false, err
+	}
+	return attrs.Carrier, nil
+}
+
+// SetCarrier set carrier state by TUNSETCARRIER, kernel not route packet to
+// device when carrier off, require linux 4.19+
+func (t *TunTap) SetCarrier(on bool) error {
+	raw, err := t.fd.SyscallConn()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var v int
+	if on {
+		v = 1
+	}
+	var operr error
+	if err := raw.Control(func(fd uintptr) {
+		operr = unix.IoctlSetPointerInt(int(fd), unix.TUNSETCARRIER, v)
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(operr)
+}
@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"net/netip"
+
+	netcall "github.com/lysShub/netkit/syscall"
+)
+
+func (c *Configs) netns() (*netcall.NetNS, error) {
+	if c.nsPath != "" {
+		return netcall.OpenNetNS(c.nsPath)
+	} else if c.nsFd >= 0 {
+		return netcall.NetNSFromFd(c.nsFd)
+	}
+	return nil, nil
+}
+
+// do run fn inside the namespace of device
+func (t *TunTap) do(fn func() error) error {
+	if t.ns == nil {
+		return fn()
+	}
+	return t.ns.Do(fn)
+}
+
+// NetNS network namespace of device, nil means caller's namespace, it's owned
+// by TunTap, not close it.
+func (t *TunTap) NetNS() *netcall.NetNS { return t.ns }
+
+// MoveNetNS move device to another network namespace, the kernel down the
+// device and flush its addresses, require re-config after moved. notice other
+// queues of multi-queue device not be updated.
+func (t *TunTap) MoveNetNS(ns *netcall.NetNS) error {
+	dst, err := ns.Dup()
+	if err != nil {
+		return err
+	}
+
+	err = t.do(func() error {
+		ifi, err := t.index()
+		if err != nil {
+			return err
+		}
+		return netcall.SetLinkNetNS(ifi, dst)
+	})
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	if t.ns != nil {
+		t.ns.Close()
+	}
+	t.ns, t.addr = dst, netip.Prefix{}
+	return nil
+}
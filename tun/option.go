@@ -0,0 +1,62 @@
+package tun
+
+type Option func(*Configs)
+
+func Options(opts ...Option) *Configs {
+	cfg := &Configs{
+		owner: -1,
+		group: -1,
+		nsFd:  -1,
+	}
+	for _, e := range opts {
+		e(cfg)
+	}
+	return cfg
+}
+
+// Persist create persistent device by TUNSETPERSIST, the device not be deleted
+// after close, can re-open by Open, and delete by Delete.
+func Persist(c *Configs) {
+	c.persist = true
+}
+
+// Owner set owner uid of device by TUNSETOWNER, the unprivileged process of
+// the owner can Open the device.
+func Owner(uid int) Option {
+	return func(c *Configs) {
+		c.owner = uid
+	}
+}
+
+// Group set group gid of device by TUNSETGROUP, the unprivileged process of
+// the group can Open the device.
+func Group(gid int) Option {
+	return func(c *Configs) {
+		c.group = gid
+	}
+}
+
+// NetNS create device inside the network namespace, path such as
+// /proc/<pid>/ns/net, or name(not contain '/') under /var/run/netns that
+// created by `ip netns add`. the caller's thread stay in its own namespace.
+func NetNS(path string) Option {
+	return func(c *Configs) {
+		c.nsPath, c.nsFd = path, -1
+	}
+}
+
+// NetNSFd create device inside the network namespace referred by fd, the fd
+// is duplicated, caller still own it.
+func NetNSFd(fd int) Option {
+	return func(c *Configs) {
+		c.nsPath, c.nsFd = "", fd
+	}
+}
+
+type Configs struct {
+	persist      bool
+	owner, group int
+
+	nsPath string
+	nsFd   int
+}
@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lysShub/netkit/errorx"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func (t *TunTap) config(cfg *Configs) error {
+	if cfg.owner >= 0 {
+		if err := t.ioctl(unix.TUNSETOWNER, cfg.owner); err != nil {
+			return err
+		}
+	}
+	if cfg.group >= 0 {
+		if err := t.ioctl(unix.TUNSETGROUP, cfg.group); err != nil {
+			return err
+		}
+	}
+	if cfg.persist {
+		return t.SetPersist(true)
+	}
+	return nil
+}
+
+// SetPersist set device persistent or not, the persistent device not be
+// deleted after close.
+func (t *TunTap) SetPersist(persist bool) error {
+	var v int
+	if persist {
+		v = 1
+	}
+	return t.ioctl(unix.TUNSETPERSIST, v)
+}
+
+// ioctl tun ioctl with int argument
+func (t *TunTap) ioctl(req uint, v int) error {
+	raw, err := t.fd.SyscallConn()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var operr error
+	if err := raw.Control(func(fd uintptr) {
+		operr = unix.IoctlSetInt(int(fd), req, v)
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(operr)
+}
+
+// Open open exist persistent device, the process require CAP_NET_ADMIN or is
+// owner/group of the device, see Owner and Group option. opened device not
+// be deleted after close.
+func Open(name string) (*TunTap, error) {
+	flags, err := devFlags(name)
+	if err != nil {
+		return nil, err
+	}
+	if flags&unix.IFF_PERSIST == 0 {
+		return nil, errors.Errorf("device %s is not persistent", name)
+	}
+	return create(name, flags&^unix.IFF_PERSIST, nil)
+}
+
+// Delete delete persistent device
+func Delete(name string) error {
+	t, err := Open(name)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	return t.SetPersist(false)
+}
+
+// devFlags get flags of exist tun device, such as:
+//
+//	$ cat /sys/class/net/tun0/tun_flags
+//	0x1802
+func devFlags(name string) (uint32, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/tun_flags", name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, errorx.WrapNotfound(errors.Errorf("not found tun device %s", name))
+		}
+		return 0, errors.WithStack(err)
+	}
+
+	flags, err := strconv.ParseUint(strings.TrimSpace(string(b)), 0, 32)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return uint32(flags), nil
+}
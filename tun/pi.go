@@ -0,0 +1,62 @@
+package tun
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// PacketInfo struct tun_pi, prepend to every packet of device without
+// IFF_NO_PI, https://www.kernel.org/doc/Documentation/networking/tuntap.txt
+type PacketInfo struct {
+	// Flags TunPktStrip means packet truncated, because read buffer too small
+	Flags uint16
+
+	// Proto ethernet protocol of packet, such as unix.ETH_P_IP, unix.ETH_P_MPLS_UC,
+	// for tun device kernel use it as protocol of written packet, for tap device
+	// it's ignored.
+	Proto uint16
+}
+
+const PacketInfoSize = 4
+
+const TunPktStrip uint16 = 0x0001 // TUN_PKT_STRIP
+
+// Decode decode tun_pi from b, flags is native endian, proto is big endian
+func (p *PacketInfo) Decode(b []byte) error {
+	if len(b) < PacketInfoSize {
+		return errors.Errorf("invalid tun_pi size %d", len(b))
+	}
+	p.Flags = binary.NativeEndian.Uint16(b[0:])
+	p.Proto = binary.BigEndian.Uint16(b[2:])
+	return nil
+}
+
+func (p *PacketInfo) Encode(b []byte) error {
+	if len(b) < PacketInfoSize {
+		return errors.Errorf("invalid tun_pi size %d", len(b))
+	}
+	binary.NativeEndian.PutUint16(b[0:], p.Flags)
+	binary.BigEndian.PutUint16(b[2:], p.Proto)
+	return nil
+}
+
+// packetProto infer ethernet protocol of ip(tun)/eth(tap) packet
+func packetProto(pkt []byte, tun bool) (uint16, error) {
+	if !tun {
+		if len(pkt) < header.EthernetMinimumSize {
+			return 0, errors.Errorf("invalid ethernet packet %x", pkt)
+		}
+		return uint16(header.Ethernet(pkt).Type()), nil
+	}
+
+	switch header.IPVersion(pkt) {
+	case header.IPv4Version:
+		return uint16(header.IPv4ProtocolNumber), nil
+	case header.IPv6Version:
+		return uint16(header.IPv6ProtocolNumber), nil
+	default:
+		return 0, errors.New("unknown packet protocol, require specify by WriteInfo")
+	}
+}
@@ -0,0 +1,97 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// PacketInfo whether is device without IFF_NO_PI, every packet with tun_pi
+func (t *TunTap) PacketInfo() bool { return t.flags&unix.IFF_NO_PI == 0 }
+
+// ReadInfo read packet with tun_pi, require device without IFF_NO_PI. for
+// IFF_VNET_HDR device, the virtio_net_hdr be ignored, should use ReadGSO.
+func (t *TunTap) ReadInfo(ctx context.Context, b []byte) (pi PacketInfo, n int, err error) {
+	if !t.PacketInfo() {
+		return PacketInfo{}, 0, errors.New("require device without IFF_NO_PI")
+	}
+	pi, _, n, err = t.readHdr(ctx, b)
+	return pi, n, err
+}
+
+// WriteInfo write packet with tun_pi, require device without IFF_NO_PI, can
+// write non-ip packet to tun device, such as:
+//
+//	WriteInfo(ctx, tun.PacketInfo{Proto: unix.ETH_P_MPLS_UC}, mpls)
+func (t *TunTap) WriteInfo(ctx context.Context, pi PacketInfo, b []byte) (int, error) {
+	if !t.PacketInfo() {
+		return 0, errors.New("require device without IFF_NO_PI")
+	}
+	return t.writeHdr(ctx, pi, VirtioNetHdr{}, b)
+}
+
+// hdrSize size of headers prepend to packet, tun_pi before virtio_net_hdr
+func (t *TunTap) hdrSize() int {
+	var n int
+	if t.PacketInfo() {
+		n += PacketInfoSize
+	}
+	if t.VnetHdr() {
+		n += VirtioNetHdrSize
+	}
+	return n
+}
+
+// readHdr read packet and decode headers of device
+func (t *TunTap) readHdr(ctx context.Context, b []byte) (pi PacketInfo, hdr VirtioNetHdr, n int, err error) {
+	var h [PacketInfoSize + VirtioNetHdrSize]byte
+	hs := h[:t.hdrSize()]
+
+	n, err = t.read(ctx, [][]byte{hs, b})
+	if err != nil {
+		return PacketInfo{}, VirtioNetHdr{}, 0, err
+	} else if n < len(hs) {
+		return PacketInfo{}, VirtioNetHdr{}, 0, errors.Errorf("recved invalid packet size %d", n)
+	}
+
+	if t.PacketInfo() {
+		if err := pi.Decode(hs); err != nil {
+			return PacketInfo{}, VirtioNetHdr{}, 0, err
+		}
+		hs = hs[PacketInfoSize:]
+	}
+	if t.VnetHdr() {
+		if err := hdr.Decode(hs); err != nil {
+			return PacketInfo{}, VirtioNetHdr{}, 0, err
+		}
+	}
+	return pi, hdr, n - t.hdrSize(), nil
+}
+
+// writeHdr encode headers of device and write packet
+func (t *TunTap) writeHdr(ctx context.Context, pi PacketInfo, hdr VirtioNetHdr, b []byte) (int, error) {
+	var h [PacketInfoSize + VirtioNetHdrSize]byte
+	hs := h[:t.hdrSize()]
+
+	if t.PacketInfo() {
+		if err := pi.Encode(hs); err != nil {
+			return 0, err
+		}
+		hs = hs[PacketInfoSize:]
+	}
+	if t.VnetHdr() {
+		if err := hdr.Encode(hs); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := t.write(ctx, [][]byte{h[:t.hdrSize()], b})
+	if err != nil {
+		return 0, err
+	}
+	return max(n-t.hdrSize(), 0), nil
+}
@@ -0,0 +1,163 @@
+package tun
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Device packet device, such as TunTap, Pipe
+type Device interface {
+	// Read read a packet, b should be large enough to hold the packet
+	Read(ctx context.Context, b []byte) (int, error)
+
+	// Write write a packet
+	Write(ctx context.Context, b []byte) (int, error)
+
+	Close() error
+}
+
+var _ Device = (*Pipe)(nil)
+
+// Pipe in-memory virtual device, packet written to one end can be read from
+// the other end, used to test code that takes a Device without privileges.
+type Pipe struct {
+	cfg  *PipeConfigs
+	in   chan pipePacket // read queue of the end
+	peer *Pipe
+
+	readMu  sync.Mutex
+	pending *pipePacket
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type pipePacket struct {
+	b  []byte
+	at time.Time // deliver time
+}
+
+type PipeOption func(*PipeConfigs)
+
+type PipeConfigs struct {
+	mtu     int
+	latency time.Duration
+	queue   int
+}
+
+// PipeMTU max packet size can be written, default 1500
+func PipeMTU(mtu int) PipeOption {
+	return func(c *PipeConfigs) { c.mtu = mtu }
+}
+
+// PipeLatency one-way delay of packet, default 0
+func PipeLatency(latency time.Duration) PipeOption {
+	return func(c *PipeConfigs) { c.latency = latency }
+}
+
+// PipeQueue queue depth of each direction, Write block when queue full,
+// default 64
+func PipeQueue(n int) PipeOption {
+	return func(c *PipeConfigs) { c.queue = n }
+}
+
+// NewPipe create a connected pair of Pipe
+//
+// e.g:
+// a, b := tun.NewPipe(tun.PipeMTU(1400), tun.PipeLatency(time.Millisecond*10))
+func NewPipe(opts ...PipeOption) (*Pipe, *Pipe) {
+	var cfg = &PipeConfigs{mtu: 1500, queue: 64}
+	for _, fn := range opts {
+		fn(cfg)
+	}
+	cfg.queue = max(cfg.queue, 1)
+
+	var a = &Pipe{cfg: cfg, in: make(chan pipePacket, cfg.queue), done: make(chan struct{})}
+	var b = &Pipe{cfg: cfg, in: make(chan pipePacket, cfg.queue), done: make(chan struct{})}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (p *Pipe) MTU() int { return p.cfg.mtu }
+
+// Read read a packet written to peer, return io.ErrShortBuffer if b too small,
+// the packet is discarded. return io.EOF after peer closed and queue drained.
+func (p *Pipe) Read(ctx context.Context, b []byte) (int, error) {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+
+	if p.pending == nil {
+		select {
+		case pkt := <-p.in:
+			p.pending = &pkt
+		case <-p.done:
+			return 0, errors.WithStack(os.ErrClosed)
+		case <-ctx.Done():
+			return 0, errors.WithStack(ctx.Err())
+		case <-p.peer.done:
+			select {
+			case pkt := <-p.in:
+				p.pending = &pkt
+			default:
+				return 0, io.EOF
+			}
+		}
+	}
+
+	if d := time.Until(p.pending.at); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-p.done:
+			return 0, errors.WithStack(os.ErrClosed)
+		case <-ctx.Done():
+			return 0, errors.WithStack(ctx.Err()) // keep pending packet
+		}
+	}
+
+	pkt := p.pending
+	p.pending = nil
+	if len(b) < len(pkt.b) {
+		return 0, errors.WithStack(io.ErrShortBuffer)
+	}
+	return copy(b, pkt.b), nil
+}
+
+// Write write a packet to peer, block when queue full.
+func (p *Pipe) Write(ctx context.Context, b []byte) (int, error) {
+	if len(b) > p.cfg.mtu {
+		return 0, errors.Errorf("packet size %d exceed mtu %d", len(b), p.cfg.mtu)
+	}
+	select {
+	case <-p.done:
+		return 0, errors.WithStack(os.ErrClosed)
+	case <-p.peer.done:
+		return 0, errors.WithStack(io.ErrClosedPipe)
+	default:
+	}
+
+	pkt := pipePacket{b: append([]byte{}, b...), at: time.Now().Add(p.cfg.latency)}
+	select {
+	case p.peer.in <- pkt:
+		return len(b), nil
+	case <-p.done:
+		return 0, errors.WithStack(os.ErrClosed)
+	case <-p.peer.done:
+		return 0, errors.WithStack(io.ErrClosedPipe)
+	case <-ctx.Done():
+		return 0, errors.WithStack(ctx.Err())
+	}
+}
+
+// Close close the end, pending Read/Write return os.ErrClosed, peer's Write
+// return io.ErrClosedPipe.
+func (p *Pipe) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
@@ -0,0 +1,123 @@
+package tun_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lysShub/netkit/tun"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Pipe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("read write", func(t *testing.T) {
+		a, b := tun.NewPipe()
+		defer a.Close()
+		defer b.Close()
+
+		n, err := a.Write(ctx, []byte("hello"))
+		require.NoError(t, err)
+		require.Equal(t, 5, n)
+		n, err = b.Write(ctx, []byte("world"))
+		require.NoError(t, err)
+		require.Equal(t, 5, n)
+
+		var buf = make([]byte, 1500)
+		n, err = b.Read(ctx, buf)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf[:n]))
+		n, err = a.Read(ctx, buf)
+		require.NoError(t, err)
+		require.Equal(t, "world", string(buf[:n]))
+	})
+
+	t.Run("mtu", func(t *testing.T) {
+		a, b := tun.NewPipe(tun.PipeMTU(100))
+		defer a.Close()
+		defer b.Close()
+
+		_, err := a.Write(ctx, make([]byte, 100))
+		require.NoError(t, err)
+		_, err = a.Write(ctx, make([]byte, 101))
+		require.Error(t, err)
+
+		_, err = b.Read(ctx, make([]byte, 64))
+		require.True(t, errors.Is(err, io.ErrShortBuffer))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		const latency = time.Millisecond * 50
+		a, b := tun.NewPipe(tun.PipeLatency(latency))
+		defer a.Close()
+		defer b.Close()
+
+		start := time.Now()
+		_, err := a.Write(ctx, []byte("hello"))
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), latency)
+
+		// cancel during delay not lose packet
+		cctx, cancel := context.WithTimeout(ctx, latency/5)
+		defer cancel()
+		var buf = make([]byte, 1500)
+		_, err = b.Read(cctx, buf)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		n, err := b.Read(ctx, buf)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf[:n]))
+		require.GreaterOrEqual(t, time.Since(start), latency)
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		a, b := tun.NewPipe(tun.PipeQueue(2))
+		defer a.Close()
+		defer b.Close()
+
+		for i := 0; i < 2; i++ {
+			_, err := a.Write(ctx, []byte{byte(i)})
+			require.NoError(t, err)
+		}
+		cctx, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+		defer cancel()
+		_, err := a.Write(cctx, []byte{2})
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		var buf = make([]byte, 1500)
+		for i := 0; i < 2; i++ {
+			n, err := b.Read(ctx, buf)
+			require.NoError(t, err)
+			require.Equal(t, []byte{byte(i)}, buf[:n])
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		a, b := tun.NewPipe()
+		defer b.Close()
+
+		_, err := a.Write(ctx, []byte("hello"))
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			a.Close()
+		}()
+		_, err = a.Read(ctx, make([]byte, 1500))
+		require.True(t, errors.Is(err, os.ErrClosed))
+
+		_, err = b.Write(ctx, []byte("world"))
+		require.True(t, errors.Is(err, io.ErrClosedPipe))
+
+		var buf = make([]byte, 1500)
+		n, err := b.Read(ctx, buf)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf[:n]))
+		_, err = b.Read(ctx, buf)
+		require.Equal(t, io.EOF, err)
+	})
+}
@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// CreateMultiQueue create IFF_MULTI_QUEUE device with queues queue, return a
+// TunTap per queue, each queue can be read/write by independent goroutine.
+// the device be deleted after all queues closed, except Persist device.
+//
+// e.g:
+// CreateMultiQueue("tun0", unix.IFF_TUN|unix.IFF_NO_PI, runtime.NumCPU())
+func CreateMultiQueue(name string, flags uint32, queues int, opts ...Option) ([]*TunTap, error) {
+	if queues <= 0 {
+		return nil, errors.Errorf("invalid queues %d", queues)
+	}
+
+	first, err := Create(name, flags|unix.IFF_MULTI_QUEUE, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var qs = []*TunTap{first}
+	for i := 1; i < queues; i++ {
+		q, err := first.NewQueue()
+		if err != nil {
+			for _, e := range qs {
+				e.Close()
+			}
+			return nil, err
+		}
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// MultiQueue whether is IFF_MULTI_QUEUE device
+func (t *TunTap) MultiQueue() bool { return t.flags&unix.IFF_MULTI_QUEUE != 0 }
+
+// NewQueue open a new queue of the multi-queue device, used to scale up queues
+// at runtime, scale down by Close the queue.
+func (t *TunTap) NewQueue() (*TunTap, error) {
+	if !t.MultiQueue() {
+		return nil, errors.New("not multi queue device")
+	}
+
+	var ns *netcall.NetNS
+	if t.ns != nil {
+		var err error
+		if ns, err = t.ns.Dup(); err != nil {
+			return nil, err
+		}
+	}
+	q, err := create(t.name, t.flags, ns)
+	if err != nil {
+		if ns != nil {
+			ns.Close()
+		}
+		return nil, err
+	}
+	q.addr = t.addr
+	return q, nil
+}
+
+// Detach detach the queue from device, kernel will not deliver packet to
+// detached queue, but the fd still valid and can re-Attach. notice can't
+// detach the last attached queue.
+func (t *TunTap) Detach() error {
+	return t.setQueue(unix.IFF_DETACH_QUEUE)
+}
+
+// Attach re-attach the queue detached by Detach
+func (t *TunTap) Attach() error {
+	return t.setQueue(unix.IFF_ATTACH_QUEUE)
+}
+
+func (t *TunTap) setQueue(flags uint32) error {
+	if !t.MultiQueue() {
+		return errors.New("not multi queue device")
+	}
+
+	raw, err := t.fd.SyscallConn()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var operr error
+	if err := raw.Control(func(fd uintptr) {
+		var ifq *unix.Ifreq
+		if ifq, operr = unix.NewIfreq(t.name); operr != nil {
+			return
+		}
+		ifq.SetUint32(flags)
+		operr = unix.IoctlIfreq(int(fd), unix.TUNSETQUEUE, ifq)
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(operr)
+}
@@ -5,9 +5,12 @@ package tun
 
 import (
 	"context"
+	"io"
 	"net"
 	"net/netip"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -18,19 +21,27 @@ import (
 
 const cloneTunPath = "/dev/net/tun"
 
+var _ Device = (*TunTap)(nil)
+
 type TunTap struct {
-	fd   *os.File
-	name string
-	addr netip.Prefix
-	tun  bool
+	fd    *os.File
+	name  string
+	addr  netip.Prefix
+	tun   bool
+	flags uint32
+	ns    *netcall.NetNS // nil means caller's namespace
+
+	rd, wd deadline
+
+	closed atomic.Bool
 }
 
-func Tun(name string) (*TunTap, error) {
-	return Create(name, unix.IFF_TUN|unix.IFF_NO_PI)
+func Tun(name string, opts ...Option) (*TunTap, error) {
+	return Create(name, unix.IFF_TUN|unix.IFF_NO_PI, opts...)
 }
 
-func Tap(name string) (*TunTap, error) {
-	return Create(name, unix.IFF_TAP|unix.IFF_NO_PI)
+func Tap(name string, opts ...Option) (*TunTap, error) {
+	return Create(name, unix.IFF_TAP|unix.IFF_NO_PI, opts...)
 }
 
 // Create
@@ -38,8 +49,37 @@ func Tap(name string) (*TunTap, error) {
 // e.g:
 // Create("tun0", unix.IFF_TUN)
 // Create("tap0", unix.IFF_TAP|unix.IFF_TUN_EXCL)
-func Create(name string, flags uint32) (*TunTap, error) {
-	var tap = &TunTap{}
+// Create("tun0", unix.IFF_TUN|unix.IFF_NO_PI, tun.Persist, tun.Owner(1000))
+// Create("tun0", unix.IFF_TUN|unix.IFF_NO_PI, tun.NetNS("ns1"))
+func Create(name string, flags uint32, opts ...Option) (*TunTap, error) {
+	cfg := Options(opts...)
+	ns, err := cfg.netns()
+	if err != nil {
+		return nil, err
+	}
+
+	tap, err := create(name, flags, ns)
+	if err != nil {
+		if ns != nil {
+			ns.Close()
+		}
+		return nil, err
+	}
+	if err := tap.config(cfg); err != nil {
+		tap.Close()
+		return nil, err
+	}
+
+	if err := tap.AddFlags(unix.IFF_UP | unix.IFF_RUNNING); err != nil {
+		tap.Close()
+		return nil, err
+	}
+	return tap, nil
+}
+
+// create open device, ns owned by returned TunTap
+func create(name string, flags uint32, ns *netcall.NetNS) (*TunTap, error) {
+	var tap = &TunTap{name: name, flags: flags, ns: ns}
 	if flags&unix.IFF_TUN != 0 && flags&unix.IFF_TAP == 0 {
 		tap.tun = true
 	} else if flags&unix.IFF_TUN == 0 && flags&unix.IFF_TAP != 0 {
@@ -48,9 +88,14 @@ func Create(name string, flags uint32) (*TunTap, error) {
 		return nil, errors.New("invalid flags")
 	}
 
-	fd, err := unix.Open(cloneTunPath, unix.O_RDWR, 0) // |unix.O_CLOEXEC
+	// device created in the namespace of opening clone device
+	var fd int
+	err := tap.do(func() (err error) {
+		fd, err = unix.Open(cloneTunPath, unix.O_RDWR, 0) // |unix.O_CLOEXEC
+		return errors.WithStack(err)
+	})
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
 
 	if ifq, err := unix.NewIfreq(name); err != nil {
@@ -63,7 +108,14 @@ func Create(name string, flags uint32) (*TunTap, error) {
 			unix.Close(fd)
 			return nil, errors.WithStack(err)
 		}
-		tap.name = name
+	}
+
+	if flags&unix.IFF_VNET_HDR != 0 {
+		err = unix.IoctlSetPointerInt(fd, unix.TUNSETVNETHDRSZ, VirtioNetHdrSize)
+		if err != nil {
+			unix.Close(fd)
+			return nil, errors.WithStack(err)
+		}
 	}
 
 	err = unix.SetNonblock(fd, true)
@@ -72,35 +124,47 @@ func Create(name string, flags uint32) (*TunTap, error) {
 		return nil, err
 	}
 	tap.fd = os.NewFile(uintptr(fd), cloneTunPath)
-
-	if err := tap.AddFlags(unix.IFF_UP | unix.IFF_RUNNING); err != nil {
-		tap.Close()
-		return nil, err
-	}
+	tap.rd.set, tap.wd.set = tap.fd.SetReadDeadline, tap.fd.SetWriteDeadline
 	return tap, nil
 }
 
 func (t *TunTap) Name() string { return t.name }
-func (t *TunTap) Close() error { return t.fd.Close() }
 
-func (t *TunTap) Flags() (uint32, error) {
-	return netcall.IoctlGifflags(t.name)
+// Close close device, pending Read/Write return os.ErrClosed
+func (t *TunTap) Close() error {
+	t.closed.Store(true)
+	if t.ns != nil {
+		t.ns.Close()
+	}
+	return t.fd.Close()
+}
+
+func (t *TunTap) Flags() (flags uint32, err error) {
+	err = t.do(func() error {
+		flags, err = netcall.IoctlGifflags(t.name)
+		return err
+	})
+	return flags, err
 }
 
 func (t *TunTap) AddFlags(flags uint32) error {
-	return netcall.IoctlAifflags(t.name, flags)
+	return t.do(func() error { return netcall.IoctlAifflags(t.name, flags) })
 }
 
 func (t *TunTap) DelFlags(flags uint32) error {
-	return netcall.IoctlDifflags(t.name, flags)
+	return t.do(func() error { return netcall.IoctlDifflags(t.name, flags) })
 }
 
-func (t *TunTap) Addr() (netip.Prefix, error) {
-	return netcall.IoctlGifaddr(t.name)
+func (t *TunTap) Addr() (addr netip.Prefix, err error) {
+	err = t.do(func() error {
+		addr, err = netcall.IoctlGifaddr(t.name)
+		return err
+	})
+	return addr, err
 }
 
 func (t *TunTap) SetAddr(addr netip.Prefix) error {
-	err := netcall.IoctlSifaddr(t.name, addr)
+	err := t.do(func() error { return netcall.IoctlSifaddr(t.name, addr) })
 	if err != nil {
 		return err
 	}
@@ -120,16 +184,20 @@ func (t *TunTap) SetHardware(hw net.HardwareAddr) error {
 		return errors.Errorf("invalid hardware address %s", hw.String())
 	}
 
+	return t.do(func() error { return t.setHardware(hw) })
+}
+
+func (t *TunTap) setHardware(hw net.HardwareAddr) error {
 	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, 0)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer unix.Close(fd)
 	// must down nic before set hardware
-	if err := t.DelFlags(unix.IFF_UP | unix.IFF_RUNNING); err != nil {
+	if err := netcall.IoctlDifflags(t.name, unix.IFF_UP|unix.IFF_RUNNING); err != nil {
 		return err
 	}
-	defer t.AddFlags(unix.IFF_UP | unix.IFF_RUNNING)
+	defer netcall.IoctlAifflags(t.name, unix.IFF_UP|unix.IFF_RUNNING)
 
 	req, err := unix.NewIfreq(t.name)
 	if err != nil {
@@ -156,32 +224,199 @@ func (t *TunTap) SetHardware(hw net.HardwareAddr) error {
 	return nil
 }
 
-func (t *TunTap) Hardware() (net.HardwareAddr, error) {
-	return netcall.IoctlGifhwaddr(t.name)
+func (t *TunTap) Hardware() (hw net.HardwareAddr, err error) {
+	err = t.do(func() error {
+		hw, err = netcall.IoctlGifhwaddr(t.name)
+		return err
+	})
+	return hw, err
 }
 
-const ctxPeriod = time.Millisecond * 100
-
-// Read read ip(tun)/eth(tap) outgoing device packet
+// Read read ip(tun)/eth(tap) outgoing device packet, for IFF_VNET_HDR device,
+// the virtio_net_hdr be stripped, if it's GSO super-packet(enabled TSO
+// offload) return error, should use ReadGSO. for device without IFF_NO_PI,
+// the tun_pi be stripped, should use ReadInfo get it.
 func (t *TunTap) Read(ctx context.Context, b []byte) (int, error) {
-	for {
-		err := t.fd.SetReadDeadline(time.Now().Add(ctxPeriod))
+	if t.PacketInfo() || t.VnetHdr() {
+		pi, hdr, n, err := t.readHdr(ctx, b)
 		if err != nil {
-			return 0, errors.WithStack(err)
+			return 0, err
+		} else if pi.Flags&TunPktStrip != 0 {
+			return 0, errors.WithStack(io.ErrShortBuffer)
+		} else if hdr.GSO() {
+			return 0, errors.Errorf("recved gso packet %d, require segment", hdr.GSOType)
 		}
+		if t.VnetHdr() {
+			if _, err = Segment(hdr, b[:n], 0); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	}
+	return t.read(ctx, [][]byte{b})
+}
+
+func (t *TunTap) read(ctx context.Context, bs [][]byte) (int, error) {
+	raw, err := t.fd.SyscallConn()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer t.rd.cancel(ctx)()
+
+	var n int
+	var operr error
+	for {
+		err = raw.Read(func(fd uintptr) (done bool) {
+			if operr = ctx.Err(); operr != nil {
+				return true
+			}
+			n, operr = unix.Readv(int(fd), bs)
+			return operr != unix.EAGAIN
+		})
+		if !t.rd.interrupted(ctx, err) {
+			break
+		}
+	}
+	if err == nil {
+		err = operr
+	}
+	if err != nil {
+		return 0, t.wrapErr(ctx, err)
+	}
+	return n, nil
+}
 
-		n, err := t.fd.Read(b)
+// Write write ip(tun)/eth(tap) income device packet, for device without
+// IFF_NO_PI, the tun_pi protocol inferred by ip version, non-ip packet should
+// use WriteInfo.
+func (t *TunTap) Write(ctx context.Context, b []byte) (int, error) {
+	if t.VnetHdr() {
+		return t.WriteGSO(ctx, VirtioNetHdr{}, b)
+	} else if t.PacketInfo() {
+		proto, err := packetProto(b, t.tun)
 		if err != nil {
-			if errors.Is(err, os.ErrDeadlineExceeded) {
-				continue
+			return 0, err
+		}
+		return t.writeHdr(ctx, PacketInfo{Proto: proto}, VirtioNetHdr{}, b)
+	}
+	return t.write(ctx, [][]byte{b})
+}
+
+func (t *TunTap) write(ctx context.Context, bs [][]byte) (int, error) {
+	raw, err := t.fd.SyscallConn()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer t.wd.cancel(ctx)()
+
+	var n int
+	var operr error
+	for {
+		err = raw.Write(func(fd uintptr) (done bool) {
+			if operr = ctx.Err(); operr != nil {
+				return true
 			}
-			return 0, errors.WithStack(err)
+			n, operr = unix.Writev(int(fd), bs)
+			return operr != unix.EAGAIN
+		})
+		if !t.wd.interrupted(ctx, err) {
+			break
 		}
-		return n, nil
 	}
+	if err == nil {
+		err = operr
+	}
+	if err != nil {
+		return 0, t.wrapErr(ctx, err)
+	}
+	return n, nil
+}
+
+// SetReadDeadline set deadline of Read, Read return os.ErrDeadlineExceeded
+// after deadline, zero means no deadline.
+func (t *TunTap) SetReadDeadline(d time.Time) error { return t.rd.Set(d) }
+
+// SetWriteDeadline set deadline of Write, zero means no deadline.
+func (t *TunTap) SetWriteDeadline(d time.Time) error { return t.wd.Set(d) }
+
+// deadline read or write deadline of the fd, shared by concurrent callers.
+// ctx done interrupt blocking caller by a past deadline, restore the deadline
+// set by Set after all cancelled callers returned, meanwhile callers with not
+// done ctx retry, so cancel one caller not affect others.
+type deadline struct {
+	mu      sync.Mutex
+	set     func(time.Time) error
+	t       time.Time     // set by Set
+	pending int           // cancelled callers not returned
+	idle    chan struct{} // closed after pending become zero
+}
+
+func (d *deadline) Set(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	if d.pending > 0 {
+		return nil // apply after interrupt finish
+	}
+	return errors.WithStack(d.set(t))
+}
+
+// cancel interrupt blocking callers when ctx done, the returned func must be
+// called after read/write.
+func (d *deadline) cancel(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	var fired = make(chan struct{})
+	after := context.AfterFunc(ctx, func() {
+		defer close(fired)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.pending++; d.pending == 1 {
+			d.idle = make(chan struct{})
+			d.set(time.Unix(1, 0))
+		}
+	})
+	return func() {
+		if !after() {
+			<-fired
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.pending--; d.pending == 0 {
+				d.set(d.t)
+				close(d.idle)
+			}
+		}
+	}
+}
+
+// interrupted whether err is caused by interrupt of other caller's ctx, should retry
+func (d *deadline) interrupted(ctx context.Context, err error) bool {
+	if !errors.Is(err, os.ErrDeadlineExceeded) || ctx.Err() != nil {
+		return false
+	}
+
+	d.mu.Lock()
+	pending, idle, t := d.pending, d.idle, d.t
+	d.mu.Unlock()
+	if pending > 0 {
+		// wait cancelled callers(maybe wait fd lock) return
+		select {
+		case <-idle:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return t.IsZero() || time.Now().Before(t)
 }
 
-// Write write ip(tun)/eth(tap) income device packet
-func (t *TunTap) Write(_ context.Context, b []byte) (int, error) {
-	return t.fd.Write(b)
+func (t *TunTap) wrapErr(ctx context.Context, err error) error {
+	if t.closed.Load() {
+		return errors.WithStack(os.ErrClosed)
+	} else if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() != nil {
+		return errors.WithStack(ctx.Err())
+	}
+	return errors.WithStack(err)
 }
@@ -5,19 +5,32 @@ package tun_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/netip"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lysShub/netkit/errorx"
+	netcall "github.com/lysShub/netkit/syscall"
+	"github.com/lysShub/netkit/test"
 	"github.com/lysShub/netkit/tun"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/network/arp"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
 )
 
 func Test_Tun_Conn(t *testing.T) {
@@ -343,3 +356,669 @@ var m = map[uint32]string{
 	0x805:  "ETH_P_X25",
 	0xf8:   "ETH_P_XDSA",
 }
+
+func Test_MultiQueue(t *testing.T) {
+	queues := func(name string) int {
+		es, err := os.ReadDir(fmt.Sprintf("/sys/class/net/%s/queues", name))
+		require.NoError(t, err)
+		n := 0
+		for _, e := range es {
+			if strings.HasPrefix(e.Name(), "rx-") {
+				n++
+			}
+		}
+		return n
+	}
+
+	qs, err := tun.CreateMultiQueue("testmq", unix.IFF_TUN|unix.IFF_NO_PI, 4)
+	require.NoError(t, err)
+	defer func() {
+		for _, e := range qs {
+			e.Close()
+		}
+	}()
+	require.Len(t, qs, 4)
+	require.Equal(t, 4, queues("testmq"))
+
+	t.Run("detach", func(t *testing.T) {
+		require.NoError(t, qs[1].Detach())
+		require.Equal(t, 3, queues("testmq"))
+
+		require.NoError(t, qs[1].Attach())
+		require.Equal(t, 4, queues("testmq"))
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		q, err := qs[0].NewQueue()
+		require.NoError(t, err)
+		require.Equal(t, 5, queues("testmq"))
+
+		require.NoError(t, q.Close())
+		require.Equal(t, 4, queues("testmq"))
+	})
+
+	t.Run("single queue", func(t *testing.T) {
+		ap, err := tun.Tun("testsq")
+		require.NoError(t, err)
+		defer ap.Close()
+
+		require.False(t, ap.MultiQueue())
+		_, err = ap.NewQueue()
+		require.Error(t, err)
+	})
+}
+
+func Test_VnetHdr(t *testing.T) {
+	ap, err := tun.Create("testvnet", unix.IFF_TUN|unix.IFF_NO_PI|unix.IFF_VNET_HDR)
+	require.NoError(t, err)
+	defer ap.Close()
+	require.True(t, ap.VnetHdr())
+	require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.6.1/24")))
+	require.NoError(t, ap.SetOffload(tun.TunFCsum|tun.TunFTSO4|tun.TunFTSO6|tun.TunFUSO4|tun.TunFUSO6))
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IP{10, 0, 6, 2}, Port: 8080})
+	require.NoError(t, err)
+	defer conn.Close()
+	raw, err := conn.SyscallConn()
+	require.NoError(t, err)
+	require.NoError(t, raw.Control(func(fd uintptr) {
+		require.NoError(t, unix.SetsockoptInt(int(fd), unix.SOL_UDP, unix.UDP_SEGMENT, 1000))
+	}))
+
+	var payload = make([]byte, 3500)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	_, err = conn.Write(payload)
+	require.NoError(t, err)
+
+	var b = make([]byte, 0xffff)
+	for {
+		hdr, n, err := ap.ReadGSO(context.Background(), b)
+		require.NoError(t, err)
+		if header.IPVersion(b) != 4 || header.IPv4(b[:n]).TransportProtocol() != header.UDPProtocolNumber {
+			continue
+		}
+		require.True(t, hdr.GSO())
+
+		segs, err := ap.Segment(hdr, b[:n])
+		require.NoError(t, err)
+		require.Len(t, segs, 4)
+		var data []byte
+		for _, e := range segs {
+			test.ValidIP(t, e)
+			data = append(data, header.UDP(header.IPv4(e).Payload()).Payload()...)
+		}
+		require.Equal(t, payload, data)
+		break
+	}
+
+	// write gso packet
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{10, 0, 6, 1}, Port: 8080})
+	require.NoError(t, err)
+	defer l.Close()
+
+	pkt := buildPacket(t, netip.MustParseAddr("10.0.6.2"), netip.MustParseAddr("10.0.6.1"), header.UDPProtocolNumber, payload)
+	hdr, err := tun.NewGSOHdr(pkt, 0, 1000)
+	require.NoError(t, err)
+	_, err = ap.WriteGSO(context.Background(), hdr, pkt)
+	require.NoError(t, err)
+
+	var data []byte
+	for i := 0; i < 4; i++ {
+		require.NoError(t, l.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := l.Read(b)
+		require.NoError(t, err)
+		data = append(data, b[:n]...)
+	}
+	require.Equal(t, payload, data)
+}
+
+func Test_Read_Context(t *testing.T) {
+	ap, err := tun.Tun("testctx")
+	require.NoError(t, err)
+	defer ap.Close()
+	require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.7.1/24")))
+
+	var b = make([]byte, 1536)
+	readUDP := func(ctx context.Context) error {
+		for {
+			n, err := ap.Read(ctx, b)
+			if err != nil {
+				return err
+			}
+			if header.IPVersion(b) == 4 && header.IPv4(b[:n]).TransportProtocol() == header.UDPProtocolNumber {
+				return nil
+			}
+		}
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		s := time.Now()
+		err := readUDP(ctx)
+		require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+		require.Less(t, time.Since(s), time.Second)
+	})
+
+	t.Run("read after cancel", func(t *testing.T) {
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IP{10, 0, 7, 2}, Port: 8080})
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+		defer cancel()
+		require.NoError(t, readUDP(ctx))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		require.NoError(t, ap.SetReadDeadline(time.Now().Add(time.Second)))
+		defer ap.SetReadDeadline(time.Time{})
+
+		ctxA, cancelA := context.WithCancel(context.Background())
+		var errA = make(chan error, 1)
+		go func() { errA <- readUDP(ctxA) }()
+		var errB = make(chan error, 1)
+		go func() {
+			var b = make([]byte, 1536)
+			for {
+				n, err := ap.Read(context.Background(), b)
+				if err != nil || (header.IPVersion(b) == 4 && header.IPv4(b[:n]).TransportProtocol() == header.UDPProtocolNumber) {
+					errB <- err
+					return
+				}
+			}
+		}()
+		time.Sleep(time.Millisecond * 100)
+
+		cancelA()
+		require.True(t, errors.Is(<-errA, context.Canceled))
+		select {
+		case err := <-errB:
+			t.Fatal("read interrupted by other ctx", err)
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IP{10, 0, 7, 2}, Port: 8080})
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, <-errB)
+
+		// deadline set by caller still valid
+		s := time.Now()
+		_, err = ap.Read(context.Background(), make([]byte, 1536))
+		for err == nil {
+			_, err = ap.Read(context.Background(), make([]byte, 1536))
+		}
+		require.True(t, errors.Is(err, os.ErrDeadlineExceeded), err)
+		require.Less(t, time.Since(s), time.Second*2)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		ap, err := tun.Tun("testctx1")
+		require.NoError(t, err)
+
+		var rerr = make(chan error, 1)
+		go func() {
+			var b = make([]byte, 1536)
+			for {
+				if _, err := ap.Read(context.Background(), b); err != nil {
+					rerr <- err
+					return
+				}
+			}
+		}()
+		time.Sleep(time.Millisecond * 100)
+		require.NoError(t, ap.Close())
+
+		select {
+		case err := <-rerr:
+			require.True(t, errors.Is(err, os.ErrClosed), err)
+		case <-time.After(time.Second):
+			t.Fatal("read not unblocked by close")
+		}
+	})
+}
+
+func Test_Link(t *testing.T) {
+	ap, err := tun.Tun("testlink")
+	require.NoError(t, err)
+	defer ap.Close()
+
+	t.Run("mtu", func(t *testing.T) {
+		require.NoError(t, ap.SetMTU(9000))
+		mtu, err := ap.MTU()
+		require.NoError(t, err)
+		require.Equal(t, 9000, mtu)
+
+		ifi, err := net.InterfaceByName("testlink")
+		require.NoError(t, err)
+		require.Equal(t, 9000, ifi.MTU)
+	})
+
+	t.Run("txqueuelen", func(t *testing.T) {
+		require.NoError(t, ap.SetTxQueueLen(2000))
+		qlen, err := ap.TxQueueLen()
+		require.NoError(t, err)
+		require.Equal(t, 2000, qlen)
+	})
+
+	t.Run("carrier", func(t *testing.T) {
+		require.NoError(t, ap.SetCarrier(false))
+		on, err := ap.Carrier()
+		require.NoError(t, err)
+		require.False(t, on)
+
+		require.NoError(t, ap.SetCarrier(true))
+		on, err = ap.Carrier()
+		require.NoError(t, err)
+		require.True(t, on)
+	})
+	t.Run("stats", func(t *testing.T) {
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.16.1/24")))
+		old, err := ap.Stats()
+		require.NoError(t, err)
+
+		var (
+			laddr = netip.MustParseAddr("10.0.16.1")
+			raddr = netip.MustParseAddr("10.0.16.2")
+		)
+		for i := 0; i < 5; i++ {
+			_, err = ap.Write(context.Background(), buildPacket(t, raddr, laddr, header.UDPProtocolNumber, make([]byte, 100)))
+			require.NoError(t, err)
+		}
+
+		new, err := ap.Stats()
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), new.RxPackets-old.RxPackets)
+		require.Equal(t, uint64(5*(20+8+100)), new.RxBytes-old.RxBytes)
+	})
+}
+
+func Test_Addrs(t *testing.T) {
+	ap, err := tun.Tun("testaddr")
+	require.NoError(t, err)
+	defer ap.Close()
+
+	var (
+		v4    = netip.MustParsePrefix("10.0.8.1/24")
+		alias = netip.MustParsePrefix("10.0.9.1/24")
+		v6    = netip.MustParsePrefix("fd00:8::1/64")
+		ptp   = netip.MustParsePrefix("10.0.10.1/32")
+		peer  = netip.MustParseAddr("10.0.10.2")
+	)
+	require.NoError(t, ap.AddAddr(v4))
+	require.NoError(t, ap.AddAddr(alias))
+	require.NoError(t, ap.AddAddr(v6, tun.NoDAD))
+	require.NoError(t, ap.AddAddr(ptp, tun.Peer(peer)))
+	require.Error(t, ap.AddAddr(v4))
+
+	find := func(addr netip.Prefix) (netcall.IfAddr, bool) {
+		addrs, err := ap.Addrs()
+		require.NoError(t, err)
+		for _, e := range addrs {
+			if e.Prefix == addr {
+				return e, true
+			}
+		}
+		return netcall.IfAddr{}, false
+	}
+	for _, e := range []netip.Prefix{v4, alias, v6, ptp} {
+		_, ok := find(e)
+		require.True(t, ok, e.String())
+	}
+	a, _ := find(v6)
+	require.NotZero(t, a.Flags&unix.IFA_F_NODAD)
+	require.Zero(t, a.Flags&unix.IFA_F_TENTATIVE)
+	a, _ = find(ptp)
+	require.Equal(t, peer, a.Peer)
+
+	// ipv6 address usable immediately
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: v6.Addr().AsSlice()})
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, ap.DelAddr(alias))
+	require.NoError(t, ap.DelAddr(v6))
+	require.NoError(t, ap.DelAddr(ptp))
+	for _, e := range []netip.Prefix{alias, v6, ptp} {
+		_, ok := find(e)
+		require.False(t, ok, e.String())
+	}
+	_, ok := find(v4)
+	require.True(t, ok)
+}
+
+func Test_Persist(t *testing.T) {
+	exist := func(name string) bool {
+		_, err := net.InterfaceByName(name)
+		return err == nil
+	}
+	sysfs := func(name, attr string) string {
+		b, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/%s", name, attr))
+		require.NoError(t, err)
+		return strings.TrimSpace(string(b))
+	}
+
+	ap, err := tun.Tun("testpersist", tun.Persist, tun.Owner(65534), tun.Group(65534))
+	require.NoError(t, err)
+	require.NoError(t, ap.Close())
+	defer tun.Delete("testpersist")
+
+	require.True(t, exist("testpersist"))
+	require.Equal(t, "65534", sysfs("testpersist", "owner"))
+	require.Equal(t, "65534", sysfs("testpersist", "group"))
+
+	t.Run("open", func(t *testing.T) {
+		ap, err := tun.Open("testpersist")
+		require.NoError(t, err)
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.11.1/24")))
+
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IP{10, 0, 11, 2}, Port: 8080})
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var b = make([]byte, 1536)
+		for {
+			n, err := ap.Read(ctx, b)
+			require.NoError(t, err)
+			if header.IPVersion(b) == 4 && header.IPv4(b[:n]).TransportProtocol() == header.UDPProtocolNumber {
+				break
+			}
+		}
+		require.NoError(t, ap.Close())
+		require.True(t, exist("testpersist"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, tun.Delete("testpersist"))
+		require.False(t, exist("testpersist"))
+
+		_, err := tun.Open("testpersist")
+		require.True(t, errorx.NotFound(err))
+	})
+
+	t.Run("not persist", func(t *testing.T) {
+		ap, err := tun.Tun("testpersist1")
+		require.NoError(t, err)
+		defer ap.Close()
+
+		_, err = tun.Open("testpersist1")
+		require.Error(t, err)
+	})
+}
+
+func Test_NetNS(t *testing.T) {
+	exist := func(name string) bool {
+		_, err := net.InterfaceByName(name)
+		return err == nil
+	}
+
+	// new network namespace, the thread is not unlocked, so that terminated
+	var ns *netcall.NetNS
+	var done = make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		err := unix.Unshare(unix.CLONE_NEWNET)
+		if err == nil {
+			ns, err = netcall.CurrentNetNS()
+		}
+		done <- err
+	}()
+	require.NoError(t, <-done)
+	defer ns.Close()
+
+	ap, err := tun.Tun("testnetns", tun.NetNSFd(ns.Fd()))
+	require.NoError(t, err)
+	defer ap.Close()
+	require.False(t, exist("testnetns"))
+	require.NoError(t, ns.Do(func() error {
+		require.True(t, exist("testnetns"))
+		return nil
+	}))
+
+	t.Run("read", func(t *testing.T) {
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.12.1/24")))
+		require.NoError(t, ns.Do(func() error {
+			conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IP{10, 0, 12, 2}, Port: 8080})
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			_, err = conn.Write([]byte("hello"))
+			return err
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var b = make([]byte, 1536)
+		for {
+			n, err := ap.Read(ctx, b)
+			require.NoError(t, err)
+			if header.IPVersion(b) == 4 && header.IPv4(b[:n]).TransportProtocol() == header.UDPProtocolNumber {
+				require.Equal(t, "hello", string(header.UDP(header.IPv4(b[:n]).Payload()).Payload()))
+				break
+			}
+		}
+
+		stats, err := ap.Stats()
+		require.NoError(t, err)
+		require.NotZero(t, stats.TxPackets)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		cur, err := netcall.CurrentNetNS()
+		require.NoError(t, err)
+		defer cur.Close()
+
+		require.NoError(t, ap.MoveNetNS(cur))
+		require.True(t, exist("testnetns"))
+		require.NoError(t, ns.Do(func() error {
+			require.False(t, exist("testnetns"))
+			return nil
+		}))
+
+		require.NoError(t, ap.AddFlags(unix.IFF_UP|unix.IFF_RUNNING))
+		require.NoError(t, ap.SetMTU(1400))
+		mtu, err := ap.MTU()
+		require.NoError(t, err)
+		require.Equal(t, 1400, mtu)
+	})
+}
+
+func Test_Endpoint(t *testing.T) {
+	newStack := func(t *testing.T, ap *tun.TunTap, addr netip.Addr) *stack.Stack {
+		ep, err := tun.NewEndpoint(ap, nil)
+		require.NoError(t, err)
+
+		st := stack.New(stack.Options{
+			NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol, arp.NewProtocol},
+			TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol},
+		})
+		t.Cleanup(func() {
+			st.Close()
+			st.Wait()
+		})
+		require.Nil(t, st.CreateNIC(1, ep))
+		require.Nil(t, st.AddProtocolAddress(1, tcpip.ProtocolAddress{
+			Protocol:          header.IPv4ProtocolNumber,
+			AddressWithPrefix: tcpip.AddrFromSlice(addr.AsSlice()).WithPrefix(),
+		}, stack.AddressProperties{}))
+		st.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: 1}})
+		return st
+	}
+	echo := func(t *testing.T, st *stack.Stack, laddr, raddr netip.Addr) {
+		l, err := gonet.ListenTCP(st, tcpip.FullAddress{NIC: 1, Addr: tcpip.AddrFromSlice(laddr.AsSlice()), Port: 8080}, header.IPv4ProtocolNumber)
+		require.NoError(t, err)
+		defer l.Close()
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+
+		conn, err := net.DialTimeout("tcp", netip.AddrPortFrom(laddr, 8080).String(), time.Second*3)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, raddr.String(), conn.LocalAddr().(*net.TCPAddr).IP.String())
+		require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second*2))) // gso packet dropped cause retransmit
+
+		var msg = make([]byte, 64*1024)
+		rand.New(rand.NewSource(0)).Read(msg)
+		go conn.Write(msg)
+		var b = make([]byte, len(msg))
+		_, err = io.ReadFull(conn, b)
+		require.NoError(t, err)
+		require.Equal(t, msg, b)
+	}
+
+	t.Run("tun", func(t *testing.T) {
+		ap, err := tun.Tun("testeptun")
+		require.NoError(t, err)
+		defer ap.Close()
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.13.1/24")))
+
+		st := newStack(t, ap, netip.MustParseAddr("10.0.13.2"))
+		echo(t, st, netip.MustParseAddr("10.0.13.2"), netip.MustParseAddr("10.0.13.1"))
+	})
+
+	t.Run("tap", func(t *testing.T) {
+		ap, err := tun.Tap("testeptap")
+		require.NoError(t, err)
+		defer ap.Close()
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.14.1/24")))
+
+		st := newStack(t, ap, netip.MustParseAddr("10.0.14.2"))
+		echo(t, st, netip.MustParseAddr("10.0.14.2"), netip.MustParseAddr("10.0.14.1"))
+	})
+
+	t.Run("vnet", func(t *testing.T) {
+		ap, err := tun.Create("testepvnet", unix.IFF_TUN|unix.IFF_NO_PI|unix.IFF_VNET_HDR)
+		require.NoError(t, err)
+		defer ap.Close()
+		require.NoError(t, ap.SetOffload(tun.TunFCsum|tun.TunFTSO4))
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.36.1/24")))
+
+		st := newStack(t, ap, netip.MustParseAddr("10.0.36.2"))
+		echo(t, st, netip.MustParseAddr("10.0.36.2"), netip.MustParseAddr("10.0.36.1"))
+	})
+
+	t.Run("tap vnet", func(t *testing.T) {
+		ap, err := tun.Create("testeptapvnet", unix.IFF_TAP|unix.IFF_NO_PI|unix.IFF_VNET_HDR)
+		require.NoError(t, err)
+		defer ap.Close()
+		require.NoError(t, ap.SetOffload(tun.TunFCsum|tun.TunFTSO4))
+		require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.42.1/24")))
+
+		st := newStack(t, ap, netip.MustParseAddr("10.0.42.2"))
+		echo(t, st, netip.MustParseAddr("10.0.42.2"), netip.MustParseAddr("10.0.42.1"))
+	})
+
+	t.Run("pi", func(t *testing.T) {
+		ap, err := tun.Create("testeppi", unix.IFF_TUN)
+		require.NoError(t, err)
+		defer ap.Close()
+
+		_, err = tun.NewEndpoint(ap, nil)
+		require.Error(t, err)
+	})
+}
+
+func Test_PacketInfo(t *testing.T) {
+	ap, err := tun.Create("testpi", unix.IFF_TUN)
+	require.NoError(t, err)
+	defer ap.Close()
+	require.True(t, ap.PacketInfo())
+	require.NoError(t, ap.SetAddr(netip.MustParsePrefix("10.0.15.1/24")))
+
+	var (
+		laddr = netip.MustParseAddr("10.0.15.1")
+		raddr = netip.MustParseAddr("10.0.15.2")
+	)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: laddr.AsSlice(), Port: 8080})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("write", func(t *testing.T) {
+		ctx := context.Background()
+		pkt := buildPacket(t, raddr, laddr, header.UDPProtocolNumber, []byte("hello"))
+		n, err := ap.Write(ctx, pkt)
+		require.NoError(t, err)
+		require.Equal(t, len(pkt), n)
+		n, err = ap.WriteInfo(ctx, tun.PacketInfo{Proto: unix.ETH_P_IP}, pkt)
+		require.NoError(t, err)
+		require.Equal(t, len(pkt), n)
+
+		var b = make([]byte, 64)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			n, err := conn.Read(b)
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(b[:n]))
+		}
+
+		_, err = ap.Write(ctx, []byte{0x00, 0x01, 0x02, 0x03})
+		require.Error(t, err)
+	})
+
+	t.Run("read", func(t *testing.T) {
+		_, err := conn.WriteToUDP([]byte("hello"), &net.UDPAddr{IP: raddr.AsSlice(), Port: 19986})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var b = make([]byte, 1536)
+		for {
+			pi, n, err := ap.ReadInfo(ctx, b)
+			require.NoError(t, err)
+			require.Zero(t, pi.Flags)
+			if header.IPVersion(b) == 4 && header.IPv4(b[:n]).TransportProtocol() == header.UDPProtocolNumber {
+				require.Equal(t, uint16(unix.ETH_P_IP), pi.Proto)
+				require.Equal(t, "hello", string(header.UDP(header.IPv4(b[:n]).Payload()).Payload()))
+				break
+			}
+		}
+	})
+
+	t.Run("short buffer", func(t *testing.T) {
+		_, err := conn.WriteToUDP([]byte("hello"), &net.UDPAddr{IP: raddr.AsSlice(), Port: 19986})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = ap.Read(ctx, make([]byte, 8))
+		require.True(t, errors.Is(err, io.ErrShortBuffer), err)
+	})
+
+	t.Run("mpls", func(t *testing.T) {
+		var mpls = []byte{0x00, 0x01, 0x01, 0x40} // label 16, bottom of stack
+		mpls = append(mpls, buildPacket(t, raddr, laddr, header.UDPProtocolNumber, []byte("hello"))...)
+
+		n, err := ap.WriteInfo(context.Background(), tun.PacketInfo{Proto: unix.ETH_P_MPLS_UC}, mpls)
+		require.NoError(t, err)
+		require.Equal(t, len(mpls), n)
+	})
+
+	t.Run("no pi", func(t *testing.T) {
+		ap, err := tun.Tun("testnopi")
+		require.NoError(t, err)
+		defer ap.Close()
+		require.False(t, ap.PacketInfo())
+
+		_, err = ap.WriteInfo(context.Background(), tun.PacketInfo{Proto: unix.ETH_P_IP}, []byte{0x45})
+		require.Error(t, err)
+	})
+}
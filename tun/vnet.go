@@ -0,0 +1,242 @@
+package tun
+
+import (
+	"encoding/binary"
+
+	"github.com/lysShub/netkit/offload"
+	"github.com/lysShub/netkit/packet"
+	"github.com/pkg/errors"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+// VirtioNetHdr struct virtio_net_hdr, prepend to every packet of IFF_VNET_HDR
+// device, https://docs.oasis-open.org/virtio/virtio/v1.2/virtio-v1.2.html 5.1.6
+type VirtioNetHdr struct {
+	Flags   uint8
+	GSOType uint8
+
+	// HdrLen link + network + transport header size
+	HdrLen uint16
+	// GSOSize max payload size of each segment
+	GSOSize uint16
+
+	// CsumStart offset of start checksum, usually is transport header
+	CsumStart uint16
+	// CsumOffset offset of checksum field after CsumStart
+	CsumOffset uint16
+}
+
+const VirtioNetHdrSize = 10
+
+const udpChecksumOffset = 6
+
+const (
+	VirtioNetHdrFNeedsCsum uint8 = 1 // VIRTIO_NET_HDR_F_NEEDS_CSUM
+	VirtioNetHdrFDataValid uint8 = 2 // VIRTIO_NET_HDR_F_DATA_VALID
+)
+
+const (
+	VirtioNetHdrGSONone  uint8 = 0    // VIRTIO_NET_HDR_GSO_NONE
+	VirtioNetHdrGSOTCPv4 uint8 = 1    // VIRTIO_NET_HDR_GSO_TCPV4
+	VirtioNetHdrGSOUDP   uint8 = 3    // VIRTIO_NET_HDR_GSO_UDP, ufo, not support
+	VirtioNetHdrGSOTCPv6 uint8 = 4    // VIRTIO_NET_HDR_GSO_TCPV6
+	VirtioNetHdrGSOUDPL4 uint8 = 5    // VIRTIO_NET_HDR_GSO_UDP_L4, uso
+	VirtioNetHdrGSOECN   uint8 = 0x80 // VIRTIO_NET_HDR_GSO_ECN
+)
+
+// Decode decode header from b, tun use native endian by default
+func (h *VirtioNetHdr) Decode(b []byte) error {
+	if len(b) < VirtioNetHdrSize {
+		return errors.Errorf("invalid virtio_net_hdr size %d", len(b))
+	}
+	h.Flags = b[0]
+	h.GSOType = b[1]
+	h.HdrLen = binary.NativeEndian.Uint16(b[2:])
+	h.GSOSize = binary.NativeEndian.Uint16(b[4:])
+	h.CsumStart = binary.NativeEndian.Uint16(b[6:])
+	h.CsumOffset = binary.NativeEndian.Uint16(b[8:])
+	return nil
+}
+
+func (h *VirtioNetHdr) Encode(b []byte) error {
+	if len(b) < VirtioNetHdrSize {
+		return errors.Errorf("invalid virtio_net_hdr size %d", len(b))
+	}
+	b[0] = h.Flags
+	b[1] = h.GSOType
+	binary.NativeEndian.PutUint16(b[2:], h.HdrLen)
+	binary.NativeEndian.PutUint16(b[4:], h.GSOSize)
+	binary.NativeEndian.PutUint16(b[6:], h.CsumStart)
+	binary.NativeEndian.PutUint16(b[8:], h.CsumOffset)
+	return nil
+}
+
+// GSO whether is GSO super-packet, need Segment
+func (h *VirtioNetHdr) GSO() bool {
+	return h.GSOType&^VirtioNetHdrGSOECN != VirtioNetHdrGSONone
+}
+
+// Segment split GSO super-packet into segments, and complete the checksum, the
+// nhoff is offset of ip header, 0 for tun, and ethernet header size for tap.
+func Segment(hdr VirtioNetHdr, pkt []byte, nhoff int) ([][]byte, error) {
+	if !hdr.GSO() {
+		if hdr.Flags&VirtioNetHdrFNeedsCsum != 0 {
+			if err := completeChecksum(hdr, pkt); err != nil {
+				return nil, err
+			}
+		}
+		return [][]byte{pkt}, nil
+	}
+
+	if nhoff < 0 || nhoff >= len(pkt) {
+		return nil, errors.Errorf("invalid network header offset %d", nhoff)
+	} else if err := checkGSO(hdr, pkt, nhoff); err != nil {
+		return nil, err
+	}
+
+	segs, err := offload.Segment(packet.From(pkt).SetHead(nhoff), int(hdr.GSOSize))
+	if err != nil {
+		return nil, err
+	} else if len(segs) == 1 {
+		if err := completeChecksum(hdr, pkt); err != nil {
+			return nil, err
+		}
+		return [][]byte{pkt}, nil
+	}
+
+	var bs = make([][]byte, 0, len(segs))
+	for _, e := range segs {
+		bs = append(bs, e.Attach(pkt[:nhoff]...).Bytes())
+	}
+	return bs, nil
+}
+
+// checkGSO check gso type match the packet's ip version and transport protocol,
+// and checksum start is the transport header.
+func checkGSO(hdr VirtioNetHdr, pkt []byte, nhoff int) error {
+	var (
+		ip    = pkt[nhoff:]
+		ipv4  bool
+		proto tcpip.TransportProtocolNumber
+		l4    int
+	)
+	switch header.IPVersion(ip) {
+	case 4:
+		if len(ip) < header.IPv4MinimumSize {
+			return errors.New("invalid ipv4 packet")
+		}
+		iphdr := header.IPv4(ip)
+		ipv4, proto, l4 = true, iphdr.TransportProtocol(), nhoff+int(iphdr.HeaderLength())
+	case 6:
+		if len(ip) < header.IPv6MinimumSize {
+			return errors.New("invalid ipv6 packet")
+		}
+		proto, l4 = header.IPv6(ip).TransportProtocol(), nhoff+header.IPv6MinimumSize
+	default:
+		return errors.Errorf("invalid ip packet version %d", header.IPVersion(ip))
+	}
+
+	var ok bool
+	switch hdr.GSOType &^ VirtioNetHdrGSOECN {
+	case VirtioNetHdrGSOTCPv4:
+		ok = ipv4 && proto == header.TCPProtocolNumber
+	case VirtioNetHdrGSOTCPv6:
+		ok = !ipv4 && proto == header.TCPProtocolNumber
+	case VirtioNetHdrGSOUDPL4:
+		ok = proto == header.UDPProtocolNumber
+	default:
+		return errors.Errorf("not support gso type %d", hdr.GSOType)
+	}
+	if !ok {
+		return errors.Errorf("gso type %d not match packet ipv4=%t protocol %d", hdr.GSOType, ipv4, proto)
+	} else if hdr.Flags&VirtioNetHdrFNeedsCsum != 0 && int(hdr.CsumStart) != l4 {
+		return errors.Errorf("invalid checksum start %d, transport header at %d", hdr.CsumStart, l4)
+	}
+	return nil
+}
+
+// completeChecksum complete VirtioNetHdrFNeedsCsum packet checksum, the checksum
+// field already hold pseudo header checksum.
+func completeChecksum(hdr VirtioNetHdr, pkt []byte) error {
+	start, off := int(hdr.CsumStart), int(hdr.CsumStart)+int(hdr.CsumOffset)
+	if off+2 > len(pkt) {
+		return errors.Errorf("invalid checksum offset %d:%d", hdr.CsumStart, hdr.CsumOffset)
+	}
+	binary.BigEndian.PutUint16(pkt[off:], ^checksum.Checksum(pkt[start:], 0))
+	return nil
+}
+
+// NewGSOHdr build virtio_net_hdr for write tcp/udp packet with offload, the
+// packet will be segmented by gsoSize if payload greater than it. notice will
+// replace transport checksum with pseudo header checksum.
+func NewGSOHdr(pkt []byte, nhoff int, gsoSize int) (VirtioNetHdr, error) {
+	if nhoff < 0 || nhoff >= len(pkt) {
+		return VirtioNetHdr{}, errors.Errorf("invalid network header offset %d", nhoff)
+	}
+
+	var (
+		ip       = pkt[nhoff:]
+		ipv4     bool
+		proto    tcpip.TransportProtocolNumber
+		src, dst tcpip.Address
+		l4       int
+	)
+	switch header.IPVersion(ip) {
+	case 4:
+		iphdr := header.IPv4(ip)
+		if !iphdr.IsValid(len(ip)) {
+			return VirtioNetHdr{}, errors.New("invalid ipv4 packet")
+		}
+		ipv4, proto, l4 = true, iphdr.TransportProtocol(), nhoff+int(iphdr.HeaderLength())
+		src, dst = iphdr.SourceAddress(), iphdr.DestinationAddress()
+	case 6:
+		iphdr := header.IPv6(ip)
+		if !iphdr.IsValid(len(ip)) {
+			return VirtioNetHdr{}, errors.New("invalid ipv6 packet")
+		}
+		// todo: support extension header
+		proto, l4 = iphdr.TransportProtocol(), nhoff+header.IPv6MinimumSize
+		src, dst = iphdr.SourceAddress(), iphdr.DestinationAddress()
+	default:
+		return VirtioNetHdr{}, errors.Errorf("invalid ip packet version %d", header.IPVersion(ip))
+	}
+
+	var hdr = VirtioNetHdr{
+		Flags:     VirtioNetHdrFNeedsCsum,
+		CsumStart: uint16(l4),
+	}
+	switch proto {
+	case header.TCPProtocolNumber:
+		if l4+header.TCPMinimumSize > len(pkt) {
+			return VirtioNetHdr{}, errors.New("invalid tcp packet")
+		}
+		hdr.HdrLen = uint16(l4 + int(header.TCP(pkt[l4:]).DataOffset()))
+		hdr.CsumOffset = header.TCPChecksumOffset
+		if ipv4 {
+			hdr.GSOType = VirtioNetHdrGSOTCPv4
+		} else {
+			hdr.GSOType = VirtioNetHdrGSOTCPv6
+		}
+	case header.UDPProtocolNumber:
+		hdr.HdrLen = uint16(l4 + header.UDPMinimumSize)
+		hdr.CsumOffset = udpChecksumOffset
+		hdr.GSOType = VirtioNetHdrGSOUDPL4
+	default:
+		return VirtioNetHdr{}, errors.Errorf("not support transport protocol %d", proto)
+	}
+	if int(hdr.HdrLen) > len(pkt) {
+		return VirtioNetHdr{}, errors.Errorf("invalid %d packet", proto)
+	}
+
+	if gsoSize > 0 && len(pkt)-int(hdr.HdrLen) > gsoSize {
+		hdr.GSOSize = uint16(gsoSize)
+	} else {
+		hdr.GSOType = VirtioNetHdrGSONone
+	}
+
+	sum := header.PseudoHeaderChecksum(proto, src, dst, uint16(len(pkt)-l4))
+	binary.BigEndian.PutUint16(pkt[l4+int(hdr.CsumOffset):], sum)
+	return hdr, nil
+}
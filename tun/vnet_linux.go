@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package tun
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// TUNSETOFFLOAD flags
+const (
+	TunFCsum   uint32 = 0x01 // TUN_F_CSUM, can receive packet without checksum
+	TunFTSO4   uint32 = 0x02 // TUN_F_TSO4, can receive tcp4 super-packet, require TunFCsum
+	TunFTSO6   uint32 = 0x04 // TUN_F_TSO6
+	TunFTSOECN uint32 = 0x08 // TUN_F_TSO_ECN
+	TunFUFO    uint32 = 0x10 // TUN_F_UFO
+	TunFUSO4   uint32 = 0x20 // TUN_F_USO4, require linux 6.2+
+	TunFUSO6   uint32 = 0x40 // TUN_F_USO6
+)
+
+// VnetHdr whether is IFF_VNET_HDR device
+func (t *TunTap) VnetHdr() bool { return t.flags&unix.IFF_VNET_HDR != 0 }
+
+// SetOffload set offload of device, require IFF_VNET_HDR device, such as:
+//
+//	SetOffload(tun.TunFCsum|tun.TunFTSO4|tun.TunFTSO6)
+//
+// after set, kernel will send GSO super-packet to the device.
+func (t *TunTap) SetOffload(flags uint32) error {
+	if !t.VnetHdr() {
+		return errors.New("require IFF_VNET_HDR device")
+	}
+
+	return t.ioctl(unix.TUNSETOFFLOAD, int(flags))
+}
+
+// ReadGSO read packet with virtio_net_hdr, the packet maybe GSO super-packet
+// (up to 64KB) or need complete checksum, can use Segment handle it.
+func (t *TunTap) ReadGSO(ctx context.Context, b []byte) (hdr VirtioNetHdr, n int, err error) {
+	if !t.VnetHdr() {
+		return VirtioNetHdr{}, 0, errors.New("require IFF_VNET_HDR device")
+	}
+
+	_, hdr, n, err = t.readHdr(ctx, b)
+	return hdr, n, err
+}
+
+// WriteGSO write packet with virtio_net_hdr, can build hdr by NewGSOHdr.
+func (t *TunTap) WriteGSO(ctx context.Context, hdr VirtioNetHdr, b []byte) (int, error) {
+	if !t.VnetHdr() {
+		return 0, errors.New("require IFF_VNET_HDR device")
+	}
+
+	var pi PacketInfo
+	if t.PacketInfo() {
+		proto, err := packetProto(b, t.tun)
+		if err != nil {
+			return 0, err
+		}
+		pi.Proto = proto
+	}
+	return t.writeHdr(ctx, pi, hdr, b)
+}
+
+// Segment split GSO super-packet read by ReadGSO, see Segment
+func (t *TunTap) Segment(hdr VirtioNetHdr, pkt []byte) ([][]byte, error) {
+	nhoff := 0
+	if !t.tun {
+		nhoff = 14 // header.EthernetMinimumSize
+	}
+	return Segment(hdr, pkt, nhoff)
+}
@@ -0,0 +1,113 @@
+package tun_test
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/lysShub/netkit/test"
+	"github.com/lysShub/netkit/tun"
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+func buildPacket(t *testing.T, src, dst netip.Addr, proto tcpip.TransportProtocolNumber, payload []byte) []byte {
+	return test.BuildIP(t,
+		netip.AddrPortFrom(src, 19986), netip.AddrPortFrom(dst, 8080), proto,
+		0xfffffff0, header.TCPFlagAck|header.TCPFlagPsh|header.TCPFlagFin, payload,
+	)
+}
+
+func Test_Segment(t *testing.T) {
+	var payload = make([]byte, 2500)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	t.Run("tcp4", func(t *testing.T) {
+		pkt := buildPacket(t, netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), header.TCPProtocolNumber, payload)
+
+		hdr, err := tun.NewGSOHdr(pkt, 0, 1000)
+		require.NoError(t, err)
+		require.Equal(t, tun.VirtioNetHdrGSOTCPv4, hdr.GSOType)
+		require.Equal(t, uint16(40), hdr.HdrLen)
+		require.Equal(t, uint16(20), hdr.CsumStart)
+
+		segs, err := tun.Segment(hdr, pkt, 0)
+		require.NoError(t, err)
+		require.Len(t, segs, 3)
+
+		var data []byte
+		for i, e := range segs {
+			test.ValidIP(t, e)
+			ip := header.IPv4(e)
+			require.Equal(t, uint16(1+i), ip.ID())
+			tcp := header.TCP(ip.Payload())
+			require.Equal(t, uint32(0xfffffff0+i*1000), tcp.SequenceNumber())
+			require.Equal(t, i == 2, tcp.Flags().Contains(header.TCPFlagFin))
+			data = append(data, tcp.Payload()...)
+		}
+		require.True(t, bytes.Equal(payload, data))
+	})
+
+	t.Run("udp6", func(t *testing.T) {
+		pkt := buildPacket(t, netip.MustParseAddr("fd00::1"), netip.MustParseAddr("fd00::2"), header.UDPProtocolNumber, payload)
+
+		hdr, err := tun.NewGSOHdr(pkt, 0, 1200)
+		require.NoError(t, err)
+		require.Equal(t, tun.VirtioNetHdrGSOUDPL4, hdr.GSOType)
+
+		segs, err := tun.Segment(hdr, pkt, 0)
+		require.NoError(t, err)
+		require.Len(t, segs, 3)
+		for _, e := range segs {
+			test.ValidIP(t, e)
+			require.Equal(t, len(e)-header.IPv6MinimumSize, int(header.UDP(header.IPv6(e).Payload()).Length()))
+		}
+	})
+
+	t.Run("need csum", func(t *testing.T) {
+		pkt := buildPacket(t, netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), header.TCPProtocolNumber, payload[:100])
+
+		hdr, err := tun.NewGSOHdr(pkt, 0, 1000)
+		require.NoError(t, err)
+		require.False(t, hdr.GSO())
+
+		segs, err := tun.Segment(hdr, pkt, 0)
+		require.NoError(t, err)
+		require.Len(t, segs, 1)
+		test.ValidIP(t, segs[0])
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		pkt6 := buildPacket(t, netip.MustParseAddr("fd00::1"), netip.MustParseAddr("fd00::2"), header.TCPProtocolNumber, payload)
+		hdr, err := tun.NewGSOHdr(pkt6, 0, 1000)
+		require.NoError(t, err)
+		hdr.GSOType = tun.VirtioNetHdrGSOTCPv4
+		_, err = tun.Segment(hdr, pkt6, 0)
+		require.Error(t, err)
+
+		pkt := buildPacket(t, netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), header.UDPProtocolNumber, payload)
+		hdr, err = tun.NewGSOHdr(pkt, 0, 1000)
+		require.NoError(t, err)
+		hdr.GSOType = tun.VirtioNetHdrGSOTCPv4
+		_, err = tun.Segment(hdr, pkt, 0)
+		require.Error(t, err)
+
+		hdr.GSOType = tun.VirtioNetHdrGSOUDPL4
+		hdr.CsumStart += 4
+		_, err = tun.Segment(hdr, pkt, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		var hdr = tun.VirtioNetHdr{Flags: 1, GSOType: 4, HdrLen: 74, GSOSize: 1440, CsumStart: 54, CsumOffset: 16}
+		var b = make([]byte, tun.VirtioNetHdrSize)
+		require.NoError(t, hdr.Encode(b))
+
+		var h tun.VirtioNetHdr
+		require.NoError(t, h.Decode(b))
+		require.Equal(t, hdr, h)
+	})
+}